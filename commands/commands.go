@@ -1 +1,47 @@
 package commands
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/httpclient"
+	"github.com/zibbp/music-utils/trackcache"
+)
+
+func tidalHttpClient(envConfig *config.Config) *http.Client {
+	return httpclient.New(httpclient.Options{
+		Name:              "tidal",
+		Timeout:           envConfig.HttpTimeout,
+		MaxRetries:        envConfig.HttpMaxRetries,
+		RequestsPerSecond: envConfig.TidalRequestsPerSecond,
+	})
+}
+
+func spotifyHttpClient(envConfig *config.Config) *http.Client {
+	return httpclient.New(httpclient.Options{
+		Name:              "spotify",
+		Timeout:           envConfig.HttpTimeout,
+		MaxRetries:        envConfig.HttpMaxRetries,
+		RequestsPerSecond: envConfig.SpotifyRequestsPerSecond,
+	})
+}
+
+func navidromeHttpClient(envConfig *config.Config) *http.Client {
+	return httpclient.New(httpclient.Options{
+		Name:              "navidrome",
+		Timeout:           envConfig.HttpTimeout,
+		MaxRetries:        envConfig.HttpMaxRetries,
+		RequestsPerSecond: envConfig.NavidromeRequestsPerSecond,
+	})
+}
+
+// openTrackCache opens the track mapping cache. Transfers still work without it so errors are only logged.
+func openTrackCache(envConfig *config.Config) *trackcache.Cache {
+	trackCache, err := trackcache.Open(envConfig.TrackCachePath)
+	if err != nil {
+		log.Warn().Err(err).Msg("error opening track cache, continuing without it")
+		return nil
+	}
+	return trackCache
+}
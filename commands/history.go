@@ -0,0 +1,211 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/history"
+	"github.com/zibbp/music-utils/spotify"
+	"github.com/zibbp/music-utils/tidal"
+	"github.com/zibbp/music-utils/utils"
+)
+
+// PrintPlaylistHistory prints the snapshots of a playlist and the tracks added and removed between them.
+// The playlist is either a Spotify/Tidal ID or the name of a saved playlist. By default the two latest
+// snapshots are compared, from and to select snapshots by name and all prints the changes between every snapshot.
+func PrintPlaylistHistory(ctx context.Context, envConfig *config.Config, playlist string, from string, to string, all bool) error {
+	provider, id, err := resolveHistoryPlaylist(playlist)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := history.List(provider, id)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no snapshots found for playlist %s", playlist)
+	}
+
+	fmt.Printf("%s playlist %s\n\n", provider, id)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, strings.Join([]string{"Snapshot", "Time", "Tracks"}, "\t"))
+	snapshotTracks := make([][]history.Track, len(snapshots))
+	for i, snapshot := range snapshots {
+		tracks, err := loadHistoryTracks(snapshot)
+		if err != nil {
+			return fmt.Errorf("error loading snapshot %s: %v", snapshot.Name, err)
+		}
+		snapshotTracks[i] = tracks
+		fmt.Fprintln(w, strings.Join([]string{snapshot.Name, snapshot.Time.Local().Format("2006-01-02 15:04:05"), strconv.Itoa(len(tracks))}, "\t"))
+	}
+	w.Flush()
+
+	if len(snapshots) < 2 && from == "" && to == "" {
+		return nil
+	}
+
+	if all {
+		for i := 1; i < len(snapshots); i++ {
+			printHistoryDiff(snapshots[i-1], snapshotTracks[i-1], snapshots[i], snapshotTracks[i])
+		}
+		return nil
+	}
+
+	fromIndex := len(snapshots) - 2
+	toIndex := len(snapshots) - 1
+	if from != "" {
+		if fromIndex = snapshotIndex(snapshots, from); fromIndex == -1 {
+			return fmt.Errorf("snapshot %s not found", from)
+		}
+	}
+	if to != "" {
+		if toIndex = snapshotIndex(snapshots, to); toIndex == -1 {
+			return fmt.Errorf("snapshot %s not found", to)
+		}
+	}
+	if fromIndex < 0 {
+		return fmt.Errorf("only one snapshot exists, nothing to compare")
+	}
+
+	printHistoryDiff(snapshots[fromIndex], snapshotTracks[fromIndex], snapshots[toIndex], snapshotTracks[toIndex])
+
+	return nil
+}
+
+func snapshotIndex(snapshots []history.Snapshot, name string) int {
+	for i, snapshot := range snapshots {
+		if snapshot.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func printHistoryDiff(fromSnapshot history.Snapshot, fromTracks []history.Track, toSnapshot history.Snapshot, toTracks []history.Track) {
+	added, removed := history.Diff(fromTracks, toTracks)
+
+	fmt.Printf("\n%s -> %s\n", fromSnapshot.Name, toSnapshot.Name)
+	if len(added) == 0 && len(removed) == 0 {
+		fmt.Println("  no changes")
+		return
+	}
+
+	for _, track := range added {
+		line := fmt.Sprintf("  + %s", track)
+		if track.AddedAt != "" {
+			line += fmt.Sprintf(" (added %s", track.AddedAt)
+			if track.AddedBy != "" {
+				line += fmt.Sprintf(" by %s", track.AddedBy)
+			}
+			line += ")"
+		}
+		fmt.Println(line)
+	}
+	for _, track := range removed {
+		fmt.Printf("  - %s\n", track)
+	}
+}
+
+// resolveHistoryPlaylist finds the provider and ID of the playlist by ID or by the name of a saved playlist
+func resolveHistoryPlaylist(playlist string) (string, string, error) {
+	providers := history.Providers(playlist)
+	if len(providers) == 1 {
+		return providers[0], playlist, nil
+	}
+	if len(providers) > 1 {
+		return "", "", fmt.Errorf("playlist %s exists for multiple providers: %s", playlist, strings.Join(providers, ", "))
+	}
+
+	spotifyStates := make(map[string]spotifyPlaylistState)
+	_ = utils.ReadJsonFromFile("/data/state", "spotify_playlists", &spotifyStates)
+	tidalStates := make(map[string]tidalPlaylistState)
+	_ = utils.ReadJsonFromFile("/data/state", "tidal_playlists", &tidalStates)
+
+	var matches [][2]string
+	for id, state := range spotifyStates {
+		if strings.EqualFold(state.Name, playlist) {
+			matches = append(matches, [2]string{"spotify", id})
+		}
+	}
+	for id, state := range tidalStates {
+		if strings.EqualFold(state.Title, playlist) {
+			matches = append(matches, [2]string{"tidal", id})
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", "", fmt.Errorf("no history found for playlist %s", playlist)
+	case 1:
+		return matches[0][0], matches[0][1], nil
+	default:
+		ids := make([]string, 0, len(matches))
+		for _, match := range matches {
+			ids = append(ids, fmt.Sprintf("%s:%s", match[0], match[1]))
+		}
+		return "", "", fmt.Errorf("multiple playlists named %s, use the ID instead: %s", playlist, strings.Join(ids, ", "))
+	}
+}
+
+func loadHistoryTracks(snapshot history.Snapshot) ([]history.Track, error) {
+	var tracks []history.Track
+
+	switch snapshot.Provider {
+	case "spotify":
+		var playlist struct {
+			Tracks []spotify.PlaylistItem `json:"tracks"`
+		}
+		if err := snapshot.Load(&playlist); err != nil {
+			return nil, err
+		}
+		for _, item := range playlist.Tracks {
+			track := history.Track{
+				AddedAt: item.AddedAt,
+				AddedBy: item.AddedBy.ID,
+			}
+			switch {
+			case item.Track != nil:
+				track.ID = string(item.Track.URI)
+				track.Title = item.Track.Name
+				if len(item.Track.Artists) > 0 {
+					track.Artist = item.Track.Artists[0].Name
+				}
+			case item.Episode != nil:
+				track.ID = string(item.Episode.URI)
+				track.Title = item.Episode.Name
+				track.Artist = item.Episode.Show.Name
+			default:
+				track.ID = "unavailable"
+				track.Title = "Unavailable item"
+			}
+			tracks = append(tracks, track)
+		}
+	case "tidal":
+		var playlist tidal.Playlist
+		if err := snapshot.Load(&playlist); err != nil {
+			return nil, err
+		}
+		for _, item := range playlist.Tracks {
+			track := history.Track{
+				ID:      strconv.FormatInt(item.ID, 10),
+				Title:   item.Title,
+				AddedAt: item.DateAdded,
+			}
+			if len(item.Artists) > 0 {
+				track.Artist = item.Artists[0].Name
+			}
+			tracks = append(tracks, track)
+		}
+	default:
+		return nil, fmt.Errorf("unknown provider %s", snapshot.Provider)
+	}
+
+	return tracks, nil
+}
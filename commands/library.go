@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/library"
+	"github.com/zibbp/music-utils/matcher"
+	"github.com/zibbp/music-utils/navidrome"
+	"github.com/zibbp/music-utils/provider"
+	"github.com/zibbp/music-utils/spotify"
+	"github.com/zibbp/music-utils/utils"
+)
+
+// IndexLocalLibrary reads the tags of every music file in dir into the library index
+func IndexLocalLibrary(ctx context.Context, envConfig *config.Config, dir string) error {
+	index, err := library.Load(envConfig.LibraryIndexPath)
+	if err != nil {
+		return fmt.Errorf("error loading library index: %v", err)
+	}
+
+	read, err := index.Scan(dir)
+	if err != nil {
+		return fmt.Errorf("error indexing %s: %v", dir, err)
+	}
+
+	if err := index.Save(); err != nil {
+		return fmt.Errorf("error saving library index: %v", err)
+	}
+
+	withISRC := 0
+	for _, file := range index.Files {
+		if file.ISRC != "" {
+			withISRC++
+		}
+	}
+
+	log.Info().Str("root", index.Root).Int("files", len(index.Files)).Int("read", read).Int("with_isrc", withISRC).Msg("indexed local library")
+
+	return nil
+}
+
+// MatchLocalPlaylists matches the tracks of the Navidrome format playlists at path, either a single file
+// or a directory of them, against the library index and prints the file each track resolved to
+func MatchLocalPlaylists(ctx context.Context, envConfig *config.Config, path string, unmatchedOnly bool) error {
+	index, err := library.Load(envConfig.LibraryIndexPath)
+	if err != nil {
+		return fmt.Errorf("error loading library index: %v", err)
+	}
+	if len(index.Files) == 0 {
+		return fmt.Errorf("the library index is empty, run local index first")
+	}
+
+	playlists, err := loadNavidromePlaylists(path)
+	if err != nil {
+		return err
+	}
+
+	libraryMatcher := library.NewMatcher(index, matcher.Default())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, strings.Join([]string{"Playlist", "#", "Track", "ISRC", "File", "Confidence"}, "\t"))
+	for _, playlist := range playlists {
+		matched := 0
+		for i, match := range libraryMatcher.MatchPlaylist(playlist) {
+			track := provider.FromMatcherTrack(match.Track).String()
+			if match.File == nil {
+				fmt.Fprintln(w, strings.Join([]string{playlist.Name, strconv.Itoa(i + 1), track, match.Track.ISRC, "not found", ""}, "\t"))
+				continue
+			}
+			matched++
+			if unmatchedOnly {
+				continue
+			}
+			fmt.Fprintln(w, strings.Join([]string{playlist.Name, strconv.Itoa(i + 1), track, match.Track.ISRC, match.File.Path, fmt.Sprintf("%.2f (%s)", match.Confidence, match.Method)}, "\t"))
+		}
+
+		log.Info().Str("playlist", playlist.Name).Int("tracks", len(playlist.Tracks)).Int("matched", matched).Msg("matched playlist against local library")
+	}
+
+	return w.Flush()
+}
+
+// localPlaylist is a playlist read from saved files to export as M3U8
+type localPlaylist struct {
+	name   string
+	tracks []matcher.Track
+}
+
+// ExportLocalPlaylists matches the playlists saved at path against the library index and writes each as an
+// M3U8 file to output. from is the format of the saved playlists, navidrome, tidal or spotify. Paths in the
+// M3U8 files are relative to libraryRoot, or the indexed directory when it is empty.
+func ExportLocalPlaylists(ctx context.Context, envConfig *config.Config, from string, path string, output string, libraryRoot string) error {
+	index, err := library.Load(envConfig.LibraryIndexPath)
+	if err != nil {
+		return fmt.Errorf("error loading library index: %v", err)
+	}
+	if len(index.Files) == 0 {
+		return fmt.Errorf("the library index is empty, run local index first")
+	}
+
+	playlists, err := loadLocalPlaylists(from, path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(output, 0755); err != nil {
+		return err
+	}
+
+	libraryMatcher := library.NewMatcher(index, matcher.Default())
+	used := make(map[string]bool)
+	for _, playlist := range playlists {
+		matches := libraryMatcher.MatchTracks(playlist.tracks)
+
+		name := m3uFileName(playlist.name)
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s (%d)", m3uFileName(playlist.name), i)
+		}
+		used[name] = true
+
+		f, err := os.Create(filepath.Join(output, name+".m3u8"))
+		if err != nil {
+			return err
+		}
+		err = index.WriteM3U8(f, playlist.name, matches, libraryRoot)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("error writing m3u8 playlist %s: %v", playlist.name, err)
+		}
+
+		matched := 0
+		for _, match := range matches {
+			if match.File != nil {
+				matched++
+			}
+		}
+		log.Info().Str("playlist", playlist.name).Int("tracks", len(matches)).Int("matched", matched).Str("file", name+".m3u8").Msg("exported m3u8 playlist")
+	}
+
+	return nil
+}
+
+func loadLocalPlaylists(from string, path string) ([]localPlaylist, error) {
+	var playlists []localPlaylist
+	switch from {
+	case "navidrome":
+		navidromePlaylists, err := loadNavidromePlaylists(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, navidromePlaylist := range navidromePlaylists {
+			playlist := localPlaylist{name: navidromePlaylist.Name}
+			for _, track := range navidromePlaylist.Tracks {
+				playlist.tracks = append(playlist.tracks, track.ToMatcherTrack())
+			}
+			playlists = append(playlists, playlist)
+		}
+	case "tidal":
+		tidalPlaylists, err := loadTidalBackups(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, tidalPlaylist := range tidalPlaylists {
+			playlist := localPlaylist{name: tidalPlaylist.Title}
+			for _, track := range tidalPlaylist.Tracks {
+				playlist.tracks = append(playlist.tracks, track.ToMatcherTrack())
+			}
+			playlists = append(playlists, playlist)
+		}
+	case "spotify":
+		spotifyPlaylists, err := loadSpotifyBackups(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, spotifyPlaylist := range spotifyPlaylists {
+			playlist := localPlaylist{name: spotifyPlaylist.Name}
+			for _, item := range spotifyPlaylist.Tracks {
+				// episodes and unavailable items can't be in the library
+				if item.Track == nil {
+					continue
+				}
+				playlist.tracks = append(playlist.tracks, spotify.ToMatcherTrack(item.Track))
+			}
+			playlists = append(playlists, playlist)
+		}
+	default:
+		return nil, fmt.Errorf("unknown playlist format %s, must be navidrome, tidal or spotify", from)
+	}
+
+	return playlists, nil
+}
+
+// m3uFileName replaces the characters of a playlist name which are not allowed in file names
+func m3uFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		name = "Untitled"
+	}
+	return name
+}
+
+// loadNavidromePlaylists reads a Navidrome format playlist file or every playlist in a directory
+func loadNavidromePlaylists(path string) ([]navidrome.Playlist, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	} else {
+		files = append(files, path)
+	}
+
+	playlists := make([]navidrome.Playlist, 0, len(files))
+	for _, file := range files {
+		var playlist navidrome.Playlist
+		if err := utils.ReadJsonFromFile(filepath.Dir(file), strings.TrimSuffix(filepath.Base(file), ".json"), &playlist); err != nil {
+			return nil, fmt.Errorf("error reading navidrome playlist %s: %v", file, err)
+		}
+		playlists = append(playlists, playlist)
+	}
+
+	return playlists, nil
+}
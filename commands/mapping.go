@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/playlistmap"
+	"github.com/zibbp/music-utils/tidal"
+)
+
+// Spotify playlist IDs used to be appended to the Tidal playlist description to link the playlists
+var spotifyDescriptionMarker = regexp.MustCompile(`(?:^|\s)([0-9A-Za-z]{22})\s*$`)
+
+// PrintPlaylistMappings prints the saved playlist mappings
+func PrintPlaylistMappings(ctx context.Context, envConfig *config.Config) error {
+	store, err := playlistmap.Load(playlistmap.DefaultPath)
+	if err != nil {
+		return fmt.Errorf("error loading playlist mappings: %v", err)
+	}
+
+	data := [][]string{
+		{"From", "Source ID", "To", "Destination ID", "Updated"},
+	}
+	for _, m := range store.List() {
+		data = append(data, []string{m.Source, m.SourceID, m.Destination, m.DestinationID, m.UpdatedAt.Local().Format("2006-01-02 15:04:05")})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	for _, row := range data {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	w.Flush()
+
+	return nil
+}
+
+// SetPlaylistMapping maps a source playlist to a destination playlist, replacing any existing mapping
+func SetPlaylistMapping(ctx context.Context, envConfig *config.Config, from string, to string, sourceId string, destinationId string) error {
+	store, err := playlistmap.Load(playlistmap.DefaultPath)
+	if err != nil {
+		return fmt.Errorf("error loading playlist mappings: %v", err)
+	}
+
+	store.Set(from, sourceId, to, destinationId)
+
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("error saving playlist mappings: %v", err)
+	}
+
+	log.Info().Str("from", from).Str("source_id", sourceId).Str("to", to).Str("destination_id", destinationId).Msg("playlist mapping set")
+
+	return nil
+}
+
+// UnsetPlaylistMapping removes the mapping of a source playlist
+func UnsetPlaylistMapping(ctx context.Context, envConfig *config.Config, from string, to string, sourceId string) error {
+	store, err := playlistmap.Load(playlistmap.DefaultPath)
+	if err != nil {
+		return fmt.Errorf("error loading playlist mappings: %v", err)
+	}
+
+	if !store.Unset(from, sourceId, to) {
+		return fmt.Errorf("no %s mapping found for %s playlist %s", to, from, sourceId)
+	}
+
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("error saving playlist mappings: %v", err)
+	}
+
+	log.Info().Str("from", from).Str("source_id", sourceId).Str("to", to).Msg("playlist mapping removed")
+
+	return nil
+}
+
+// ImportPlaylistMappings imports Spotify to Tidal mappings from the Spotify playlist IDs which older versions
+// appended to Tidal playlist descriptions. Existing mappings are kept.
+func ImportPlaylistMappings(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService) error {
+	// Initialize Tidal client
+	tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, envConfig.TidalCountryCode, tidalHttpClient(envConfig), jsonConfig)
+	if err != nil {
+		return fmt.Errorf("error initializing tidal client: %v", err)
+	}
+
+	store, err := playlistmap.Load(playlistmap.DefaultPath)
+	if err != nil {
+		return fmt.Errorf("error loading playlist mappings: %v", err)
+	}
+
+	tidalPlaylists, err := tidalClient.GetUserPlaylists()
+	if err != nil {
+		return fmt.Errorf("error getting tidal playlists: %v", err)
+	}
+
+	imported := importSpotifyDescriptionMarkers(store, tidalPlaylists)
+
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("error saving playlist mappings: %v", err)
+	}
+
+	log.Info().Int("imported", imported).Msg("imported playlist mappings from tidal descriptions")
+
+	return nil
+}
+
+// importSpotifyDescriptionMarkers adds a mapping for each Tidal playlist with a Spotify ID in its description
+func importSpotifyDescriptionMarkers(store *playlistmap.Store, tidalPlaylists []tidal.PlaylistItemV2) int {
+	imported := 0
+	for _, tidalPlaylist := range tidalPlaylists {
+		match := spotifyDescriptionMarker.FindStringSubmatch(tidalPlaylist.Data.Description)
+		if match == nil {
+			continue
+		}
+		if _, ok := store.Get("spotify", match[1], "tidal"); ok {
+			continue
+		}
+
+		store.Set("spotify", match[1], "tidal", tidalPlaylist.Data.UUID)
+		imported++
+		log.Debug().Str("spotify_id", match[1]).Str("tidal_id", tidalPlaylist.Data.UUID).Str("playlist", tidalPlaylist.Data.Title).Msg("imported playlist mapping")
+	}
+
+	return imported
+}
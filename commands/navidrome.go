@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/matcher"
+	"github.com/zibbp/music-utils/navidrome"
+	"github.com/zibbp/music-utils/plan"
+	"github.com/zibbp/music-utils/playlistmap"
+	"github.com/zibbp/music-utils/provider"
+	"github.com/zibbp/music-utils/utils"
+)
+
+func newNavidromeClient(envConfig *config.Config) (*navidrome.Client, error) {
+	if envConfig.NavidromeUrl == "" || envConfig.NavidromeUsername == "" || envConfig.NavidromePassword == "" {
+		return nil, fmt.Errorf("NAVIDROME_URL, NAVIDROME_USERNAME and NAVIDROME_PASSWORD are required")
+	}
+
+	navidromeClient := navidrome.NewClient(envConfig.NavidromeUrl, envConfig.NavidromeUsername, envConfig.NavidromePassword, navidromeHttpClient(envConfig))
+	if err := navidromeClient.Ping(); err != nil {
+		return nil, fmt.Errorf("error connecting to navidrome: %v", err)
+	}
+
+	return navidromeClient, nil
+}
+
+// ImportNavidromePlaylists creates or updates Navidrome playlists from the Navidrome format playlists at path,
+// either a single file or a directory of them. Each track is matched against the library and the Navidrome
+// playlist is made to match the file. The ID of the Navidrome playlist is recorded in the file as the destination ID.
+// With dryRun the plan is printed without changing anything.
+func ImportNavidromePlaylists(ctx context.Context, envConfig *config.Config, path string, dryRun bool, format string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	dir := path
+	only := ""
+	if !info.IsDir() {
+		dir = filepath.Dir(path)
+		only = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+
+	source := navidrome.NewFileProvider(dir)
+	sourcePlaylists, err := source.ListPlaylists()
+	if err != nil {
+		return fmt.Errorf("error reading navidrome playlists: %v", err)
+	}
+
+	navidromeClient, err := newNavidromeClient(envConfig)
+	if err != nil {
+		return err
+	}
+	destination := navidrome.NewProvider(navidromeClient)
+
+	destinationPlaylists, err := destination.ListPlaylists()
+	if err != nil {
+		return fmt.Errorf("error fetching navidrome playlists: %v", err)
+	}
+
+	store, err := playlistmap.Load(playlistmap.DefaultPath)
+	if err != nil {
+		return fmt.Errorf("error loading playlist mappings: %v", err)
+	}
+
+	trackCache := openTrackCache(envConfig)
+	defer trackCache.Close()
+
+	p := plan.New("navidrome import")
+	p.Path = path
+	options := syncOptions{
+		mirror:        true,
+		updateDetails: true,
+		// playlists imported before are found by the destination ID recorded in the file, then by name
+		findPlaylist: func(sourcePlaylist provider.Playlist) (provider.Playlist, bool) {
+			var playlist navidrome.Playlist
+			if err := utils.ReadJsonFromFile(dir, sourcePlaylist.ID, &playlist); err == nil && playlist.DestinationId != "" {
+				for _, destinationPlaylist := range destinationPlaylists {
+					if destinationPlaylist.ID == playlist.DestinationId {
+						return destinationPlaylist, true
+					}
+				}
+			}
+			for _, destinationPlaylist := range destinationPlaylists {
+				if strings.EqualFold(destinationPlaylist.Name, sourcePlaylist.Name) {
+					return destinationPlaylist, true
+				}
+			}
+			return provider.Playlist{}, false
+		},
+	}
+
+	for _, sourcePlaylist := range sourcePlaylists {
+		if only != "" && sourcePlaylist.ID != only {
+			continue
+		}
+
+		log.Info().Str("playlist", sourcePlaylist.Name).Msg("planning navidrome playlist")
+
+		if _, err := planPlaylistSync(p, source, destination, matcher.Default(), trackCache, store, sourcePlaylist, destinationPlaylists, options); err != nil {
+			log.Error().Err(err).Str("playlist", sourcePlaylist.Name).Msg("error planning navidrome playlist")
+			continue
+		}
+	}
+
+	return runPlan(p, map[string]provider.Provider{destination.Name(): destination}, store, trackCache, dryRun, format)
+}
+
+// recordNavidromeImport records the destinations of the playlists imported by the plan, including those
+// imported before another playlist failed
+func recordNavidromeImport(p *plan.Plan, store *playlistmap.Store) error {
+	dir := p.Path
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	return recordNavidromeDestinations(dir, store)
+}
+
+// recordNavidromeDestinations sets the destination ID of each Navidrome format playlist in dir to the
+// Navidrome playlist it was imported to
+func recordNavidromeDestinations(dir string, store *playlistmap.Store) error {
+	source := navidrome.NewFileProvider(dir)
+	playlists, err := source.ListPlaylists()
+	if err != nil {
+		return err
+	}
+
+	for _, playlist := range playlists {
+		destinationId, ok := store.Get(source.Name(), playlist.ID, "navidrome")
+		if !ok {
+			continue
+		}
+
+		var navidromePlaylist navidrome.Playlist
+		if err := utils.ReadJsonFromFile(dir, playlist.ID, &navidromePlaylist); err != nil {
+			return err
+		}
+		if navidromePlaylist.DestinationId == destinationId {
+			continue
+		}
+
+		navidromePlaylist.DestinationId = destinationId
+		if err := utils.WriteJsonToFile(dir, playlist.ID, navidromePlaylist); err != nil {
+			return fmt.Errorf("error writing navidrome playlist: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/plan"
+	"github.com/zibbp/music-utils/playlistmap"
+	"github.com/zibbp/music-utils/provider"
+	"github.com/zibbp/music-utils/trackcache"
+)
+
+// afterApply is run after the plan of a command is applied, whether or not all steps succeeded
+var afterApply = map[string]func(p *plan.Plan, store *playlistmap.Store) error{
+	"navidrome import": recordNavidromeImport,
+}
+
+// runPlan prints the plan with dryRun, otherwise it is applied using the providers
+func runPlan(p *plan.Plan, providers map[string]provider.Provider, store *playlistmap.Store, trackCache *trackcache.Cache, dryRun bool, format string) error {
+	if dryRun {
+		return p.Print(os.Stdout, format)
+	}
+
+	if len(p.Steps) == 0 {
+		return nil
+	}
+
+	applyErr := p.Apply(providers, store, trackCache)
+
+	if hook, ok := afterApply[p.Command]; ok {
+		if err := hook(p, store); err != nil {
+			return err
+		}
+	}
+
+	return applyErr
+}
+
+// ApplyPlan executes a plan saved from a dry run of command
+func ApplyPlan(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService, command string, path string) error {
+	p, err := plan.Load(path)
+	if err != nil {
+		return fmt.Errorf("error loading plan: %v", err)
+	}
+	if p.Command != command {
+		return fmt.Errorf("plan was made by %s, not %s", p.Command, command)
+	}
+
+	providers := make(map[string]provider.Provider)
+	for _, name := range p.Providers() {
+		providers[name], err = newProvider(name, envConfig, jsonConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	store, err := playlistmap.Load(playlistmap.DefaultPath)
+	if err != nil {
+		return fmt.Errorf("error loading playlist mappings: %v", err)
+	}
+
+	trackCache := openTrackCache(envConfig)
+	defer trackCache.Close()
+
+	applyErr := p.Apply(providers, store, trackCache)
+
+	if hook, ok := afterApply[p.Command]; ok {
+		if err := hook(p, store); err != nil {
+			return err
+		}
+	}
+
+	return applyErr
+}
@@ -5,19 +5,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/history"
+	"github.com/zibbp/music-utils/matcher"
+	"github.com/zibbp/music-utils/plan"
+	"github.com/zibbp/music-utils/playlistmap"
+	"github.com/zibbp/music-utils/provider"
 	"github.com/zibbp/music-utils/spotify"
-	"github.com/zibbp/music-utils/tidal"
 	"github.com/zibbp/music-utils/utils"
 )
 
-func SaveSpotifyPlaylists(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService) error {
+// spotifyPlaylistState is the last saved snapshot of a playlist, used to skip unchanged playlists
+type spotifyPlaylistState struct {
+	Name       string    `json:"name"`
+	SnapshotID string    `json:"snapshot_id"`
+	SavedAt    time.Time `json:"saved_at"`
+}
+
+// SaveSpotifyPlaylists saves the user's playlists and library. Playlists whose snapshot has not
+// changed since the last save are skipped unless full is set.
+func SaveSpotifyPlaylists(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService, saveNavidromeFormat bool, full bool) error {
 	// Initialize Spotify client
-	spotifyClient, err := spotify.Initialize(envConfig.SpotifyClientId, envConfig.SpotifyClientSecret, envConfig.SpotifyRedirectUri, jsonConfig)
+	spotifyClient, err := spotify.Initialize(envConfig.SpotifyClientId, envConfig.SpotifyClientSecret, envConfig.SpotifyRedirectUri, spotifyHttpClient(envConfig), jsonConfig)
 	if err != nil {
 		return fmt.Errorf("error initializing spotify client: %v", err)
 	}
@@ -30,13 +45,27 @@ func SaveSpotifyPlaylists(ctx context.Context, envConfig *config.Config, jsonCon
 		return err
 	}
 
+	playlistStates := make(map[string]spotifyPlaylistState)
+	if err := utils.ReadJsonFromFile("/data/state", "spotify_playlists", &playlistStates); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Msg("error reading spotify playlist state, saving all playlists")
+	}
+
+	skipped := 0
 	for _, spotifyPlaylist := range spotifyPlaylists {
+		id := spotifyPlaylist.ID.String()
+
+		if state, ok := playlistStates[id]; !full && ok && state.SnapshotID == spotifyPlaylist.SnapshotID && utils.JsonFileExists("/data/spotify", id) && (!saveNavidromeFormat || utils.JsonFileExists("/data/navidrome", id+"_navidrome")) {
+			log.Debug().Str("playlist", spotifyPlaylist.Name).Msg("playlist unchanged, skipping")
+			skipped++
+			continue
+		}
+
 		log.Info().Str("playlist", spotifyPlaylist.Name).Msg("processing playlist")
 
-		// Get playlist tracks
-		spotifyPlaylistTracks, err := spotifyClient.GetPlaylistTracks(spotifyPlaylist.ID)
+		// Get playlist items
+		spotifyPlaylistItems, err := spotifyClient.GetPlaylistItems(spotifyPlaylist.ID)
 		if err != nil {
-			log.Error().Err(err).Msg("error getting Spotify playlist tracks")
+			log.Error().Err(err).Msg("error getting Spotify playlist items")
 			continue
 		}
 
@@ -46,21 +75,248 @@ func SaveSpotifyPlaylists(ctx context.Context, envConfig *config.Config, jsonCon
 		json.Unmarshal(playlistJson, &playlistMap)       // Convert JSON to map
 
 		// Override "tracks" field
-		playlistMap["tracks"] = spotifyPlaylistTracks
+		playlistMap["tracks"] = spotifyPlaylistItems
 
 		// Write updated playlist to file
 		if err := utils.WriteJsonToFile("/data/spotify", string(spotifyPlaylist.ID), playlistMap); err != nil {
 			log.Error().Err(err).Msg("error writing playlist to file")
 			continue
 		}
+
+		if err := history.Save("spotify", id, playlistMap, envConfig.HistoryRetention); err != nil {
+			log.Error().Err(err).Msg("error writing playlist snapshot")
+		}
+
+		if saveNavidromeFormat {
+			navidromePlaylist, err := spotifyClient.ToNavidromePlaylist(&spotify.SavedPlaylist{SimplePlaylist: spotifyPlaylist, Tracks: spotifyPlaylistItems})
+			if err != nil {
+				log.Error().Err(err).Msg("error converting spotify playlist to navidrome playlist")
+				continue
+			}
+			if err := utils.WriteJsonToFile("/data/navidrome", id+"_navidrome", navidromePlaylist); err != nil {
+				log.Error().Err(err).Msg("error writing navidrome playlist to file")
+				continue
+			}
+		}
+
+		playlistStates[id] = spotifyPlaylistState{
+			Name:       spotifyPlaylist.Name,
+			SnapshotID: spotifyPlaylist.SnapshotID,
+			SavedAt:    time.Now(),
+		}
+	}
+
+	log.Info().Int("skipped", skipped).Int("total", len(spotifyPlaylists)).Msg("saved spotify playlists")
+
+	if err := utils.WriteJsonToFile("/data/state", "spotify_playlists", playlistStates); err != nil {
+		return fmt.Errorf("error writing spotify playlist state: %v", err)
+	}
+
+	return saveSpotifyLibrary(spotifyClient)
+}
+
+// saveSpotifyLibrary writes the user's liked songs, saved albums, followed artists and saved shows next to the playlists
+func saveSpotifyLibrary(spotifyClient *spotify.Service) error {
+	log.Info().Msg("fetching spotify library")
+
+	savedTracks, err := spotifyClient.GetSavedTracks()
+	if err != nil {
+		return fmt.Errorf("error getting spotify liked songs: %v", err)
+	}
+	if err := utils.WriteJsonToFile("/data/spotify", "liked_songs", savedTracks); err != nil {
+		return fmt.Errorf("error writing liked songs to file: %v", err)
+	}
+	log.Info().Int("count", len(savedTracks)).Msg("saved liked songs")
+
+	savedAlbums, err := spotifyClient.GetSavedAlbums()
+	if err != nil {
+		return fmt.Errorf("error getting spotify saved albums: %v", err)
+	}
+	if err := utils.WriteJsonToFile("/data/spotify", "saved_albums", savedAlbums); err != nil {
+		return fmt.Errorf("error writing saved albums to file: %v", err)
 	}
+	log.Info().Int("count", len(savedAlbums)).Msg("saved albums")
+
+	followedArtists, err := spotifyClient.GetFollowedArtists()
+	if err != nil {
+		return fmt.Errorf("error getting spotify followed artists: %v", err)
+	}
+	if err := utils.WriteJsonToFile("/data/spotify", "followed_artists", followedArtists); err != nil {
+		return fmt.Errorf("error writing followed artists to file: %v", err)
+	}
+	log.Info().Int("count", len(followedArtists)).Msg("saved followed artists")
+
+	savedShows, err := spotifyClient.GetSavedShows()
+	if err != nil {
+		return fmt.Errorf("error getting spotify saved shows: %v", err)
+	}
+	if err := utils.WriteJsonToFile("/data/spotify", "saved_shows", savedShows); err != nil {
+		return fmt.Errorf("error writing saved shows to file: %v", err)
+	}
+	log.Info().Int("count", len(savedShows)).Msg("saved shows")
 
 	return nil
 }
 
+// RestoreSpotifyPlaylists recreates the playlists saved at path, either a single saved playlist or a directory of them.
+// Playlists owned and followed by the user are updated and have their items replaced, others are created and
+// recorded in the playlist mappings so restoring again updates the created playlist.
+// With dryRun the plan is printed without modifying anything.
+func RestoreSpotifyPlaylists(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService, path string, dryRun bool, format string) error {
+	playlists, err := loadSpotifyBackups(path)
+	if err != nil {
+		return err
+	}
+	if len(playlists) == 0 {
+		return fmt.Errorf("no saved spotify playlists found at %s", path)
+	}
+
+	// Initialize Spotify client
+	spotifyClient, err := spotify.Initialize(envConfig.SpotifyClientId, envConfig.SpotifyClientSecret, envConfig.SpotifyRedirectUri, spotifyHttpClient(envConfig), jsonConfig)
+	if err != nil {
+		return fmt.Errorf("error initializing spotify client: %v", err)
+	}
+	spotifyProvider := spotify.NewProvider(spotifyClient)
+
+	userID, err := spotifyClient.CurrentUserID()
+	if err != nil {
+		return fmt.Errorf("error getting spotify user: %v", err)
+	}
+
+	store, err := playlistmap.Load(playlistmap.DefaultPath)
+	if err != nil {
+		return fmt.Errorf("error loading playlist mappings: %v", err)
+	}
+
+	p := plan.New("spotify restore")
+
+	for _, savedPlaylist := range playlists {
+		name := savedPlaylist.Name
+		if name == "" {
+			name = "Untitled"
+		}
+
+		// local files can't be added with the API and unavailable items have no URI
+		tracks := make([]provider.Track, 0, len(savedPlaylist.Tracks))
+		skipped := 0
+		for _, item := range savedPlaylist.Tracks {
+			switch {
+			case item.IsLocal:
+				skipped++
+				if item.Track != nil {
+					log.Warn().Str("playlist", name).Str("track", item.Track.Name).Msg("local file can't be restored")
+				}
+			case item.Track != nil && item.Track.URI != "":
+				track := provider.FromMatcherTrack(spotify.ToMatcherTrack(item.Track))
+				track.ID = string(item.Track.URI)
+				tracks = append(tracks, track)
+			case item.Episode != nil && item.Episode.URI != "":
+				tracks = append(tracks, provider.Track{ID: string(item.Episode.URI), Title: item.Episode.Name, Artists: []string{item.Episode.Show.Name}})
+			default:
+				skipped++
+			}
+		}
+
+		// a playlist restored before is found by the mapping from the saved playlist to the playlist created for it
+		id := savedPlaylist.ID.String()
+		if mapped, ok := store.Get("spotify", savedPlaylist.ID.String(), "spotify"); ok {
+			id = mapped
+		}
+
+		restored := provider.Playlist{
+			ID:            id,
+			Name:          name,
+			Description:   savedPlaylist.Description,
+			Public:        savedPlaylist.IsPublic,
+			Collaborative: savedPlaylist.Collaborative,
+		}
+
+		existing, err := spotifyClient.GetPlaylist(id)
+		if err != nil && !spotify.IsNotFound(err) {
+			return fmt.Errorf("error fetching spotify playlist %s: %v", name, err)
+		}
+		// deleting a playlist only unfollows it, it can still be fetched
+		follows := false
+		if err == nil && existing.Owner.ID == userID {
+			follows, err = spotifyClient.FollowsPlaylist(id, userID)
+			if err != nil {
+				return fmt.Errorf("error checking if spotify playlist %s is followed: %v", name, err)
+			}
+		}
+		if follows {
+			if existing.Name != name || existing.Description != savedPlaylist.Description || existing.IsPublic != savedPlaylist.IsPublic || existing.Collaborative != savedPlaylist.Collaborative {
+				p.Add(plan.Step{
+					Action:              plan.UpdatePlaylist,
+					Provider:            "spotify",
+					Playlist:            restored,
+					PreviousName:        existing.Name,
+					PreviousDescription: existing.Description,
+				})
+			}
+
+			current, err := spotifyProvider.GetTracks(restored.ID)
+			if err != nil {
+				log.Error().Err(err).Str("playlist", name).Msg("error getting spotify playlist items")
+				continue
+			}
+			planTrackChanges(p, "spotify", restored, current, tracks, true)
+		} else {
+			// the playlist was deleted, unfollowed or is owned by someone else, a copy is created
+			restored.ID = p.NewPlaylistID()
+			p.Add(plan.Step{
+				Action:           plan.CreatePlaylist,
+				Provider:         "spotify",
+				Playlist:         restored,
+				SourceProvider:   "spotify",
+				SourcePlaylistID: savedPlaylist.ID.String(),
+			})
+			planTrackChanges(p, "spotify", restored, nil, tracks, true)
+		}
+
+		log.Info().Str("playlist", name).Int("items", len(tracks)).Int("skipped", skipped).Msg("planned playlist restore")
+	}
+
+	return runPlan(p, map[string]provider.Provider{"spotify": spotifyProvider}, store, nil, dryRun, format)
+}
+
+// loadSpotifyBackups reads a saved playlist file or every saved playlist in a directory
+func loadSpotifyBackups(path string) ([]*spotify.SavedPlaylist, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var playlists []*spotify.SavedPlaylist
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var playlist spotify.SavedPlaylist
+		if err := json.Unmarshal(data, &playlist); err != nil || playlist.ID == "" {
+			// library files such as liked songs are skipped
+			log.Debug().Str("file", file).Msg("not a saved spotify playlist, skipping")
+			continue
+		}
+
+		playlists = append(playlists, &playlist)
+	}
+
+	return playlists, nil
+}
+
 func PrintSpotifyPlaylists(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService) error {
 	// Initialize Spotify client
-	spotifyClient, err := spotify.Initialize(envConfig.SpotifyClientId, envConfig.SpotifyClientSecret, envConfig.SpotifyRedirectUri, jsonConfig)
+	spotifyClient, err := spotify.Initialize(envConfig.SpotifyClientId, envConfig.SpotifyClientSecret, envConfig.SpotifyRedirectUri, spotifyHttpClient(envConfig), jsonConfig)
 	if err != nil {
 		return fmt.Errorf("error initializing spotify client: %v", err)
 	}
@@ -89,84 +345,82 @@ func PrintSpotifyPlaylists(ctx context.Context, envConfig *config.Config, jsonCo
 	return nil
 }
 
-func CreateSpotifyPlaylistsOnTidal(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService) error {
-	// Initialize Spotify client
-	spotifyClient, err := spotify.Initialize(envConfig.SpotifyClientId, envConfig.SpotifyClientSecret, envConfig.SpotifyRedirectUri, jsonConfig)
+// CreateSpotifyPlaylistsOnTidal copies every Spotify playlist to Tidal. Tidal playlists are found from the saved
+// playlist mappings or the Spotify ID older versions appended to the description, otherwise they are created.
+// The Tidal name and description are kept in sync and missing tracks are added. If mirror is set extra tracks
+// are removed and the Spotify order is matched. With dryRun the plan is printed without changing anything.
+func CreateSpotifyPlaylistsOnTidal(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService, mirror bool, dryRun bool, format string) error {
+	spotifyProvider, err := newProvider("spotify", envConfig, jsonConfig)
 	if err != nil {
-		return fmt.Errorf("error initializing spotify client: %v", err)
+		return err
 	}
-
-	// Initialize Tidal client
-	tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, jsonConfig)
+	tidalProvider, err := newProvider("tidal", envConfig, jsonConfig)
 	if err != nil {
-		return fmt.Errorf("error initializing tidal client: %v", err)
+		return err
 	}
 
+	trackCache := openTrackCache(envConfig)
+	defer trackCache.Close()
+
 	// Get all user's Spotify playlists
-	spotifyPlaylists, err := spotifyClient.GetUserPlaylists()
+	spotifyPlaylists, err := spotifyProvider.ListPlaylists()
 	if err != nil {
 		return err
 	}
 
 	// Get all user's Tidal playlists
-	tidalPlaylists, err := tidalClient.GetUserPlaylists()
+	tidalPlaylists, err := tidalProvider.ListPlaylists()
 	if err != nil {
 		return fmt.Errorf("error getting tidal playlists: %v", err)
 	}
 
-	for _, spotifyPlaylist := range spotifyPlaylists {
-
-		// check if spotify playlist id exists in tidal description
-		// if it does not exist, create a new playlist
-		found := false
-		tidalPlaylist := tidal.PlaylistItemV2{}
-
-		checkString := spotifyPlaylist.ID.String()
-		playlistDescription := fmt.Sprintf("%s\n%s", spotifyPlaylist.Description, string(spotifyPlaylist.ID))
+	store, err := playlistmap.Load(playlistmap.DefaultPath)
+	if err != nil {
+		return fmt.Errorf("error loading playlist mappings: %v", err)
+	}
 
-		for _, tidtidalPlaylist := range tidalPlaylists {
-			if strings.Contains(tidtidalPlaylist.Data.Description, checkString) {
-				found = true
-				tidalPlaylist = tidtidalPlaylist
-				break
+	// migrate from the Spotify IDs older versions appended to Tidal descriptions, the ID is
+	// removed from the description by the update
+	markedPlaylists := make(map[string]provider.Playlist)
+	for _, tidalPlaylist := range tidalPlaylists {
+		if match := spotifyDescriptionMarker.FindStringSubmatch(tidalPlaylist.Description); match != nil {
+			if _, ok := markedPlaylists[match[1]]; !ok {
+				markedPlaylists[match[1]] = tidalPlaylist
 			}
 		}
+	}
 
-		if !found {
-			// create new playlist
-			var playlistName string
-			if spotifyPlaylist.Name == "" {
-				playlistName = "Untitled"
-			} else {
-				playlistName = spotifyPlaylist.Name
-			}
-			log.Info().Msgf("creating tidal playlist: %s", spotifyPlaylist.Name)
-			_, err := tidalClient.CreatePlaylist(playlistName, playlistDescription)
-			if err != nil {
-				return err
-			}
+	p := plan.New("create-tidal-playlists")
+	options := syncOptions{
+		mirror:        mirror,
+		updateDetails: true,
+		findPlaylist: func(spotifyPlaylist provider.Playlist) (provider.Playlist, bool) {
+			tidalPlaylist, ok := markedPlaylists[spotifyPlaylist.ID]
+			return tidalPlaylist, ok
+		},
+	}
+
+	for _, spotifyPlaylist := range spotifyPlaylists {
+		log.Info().Str("playlist", spotifyPlaylist.Name).Msg("planning tidal playlist")
+
+		if _, err := planPlaylistSync(p, spotifyProvider, tidalProvider, matcher.Default(), trackCache, store, spotifyPlaylist, tidalPlaylists, options); err != nil {
+			log.Error().Err(err).Str("playlist", spotifyPlaylist.Name).Msg("error planning tidal playlist")
 			continue
 		}
+	}
 
-		// check if playlist needs to be updated
-		if tidalPlaylist.Data.UUID != "" && (tidalPlaylist.Data.Title != spotifyPlaylist.Name && spotifyPlaylist.Name != "") || tidalPlaylist.Data.Description != playlistDescription {
-			log.Info().Msgf("updating tidal playlist: %s", spotifyPlaylist.Name)
-			err := tidalClient.UpdatePlaylist(tidalPlaylist.Data.UUID, spotifyPlaylist.Name, playlistDescription)
-			if err != nil {
-				return err
-			}
-		}
+	if err := runPlan(p, map[string]provider.Provider{"tidal": tidalProvider}, store, trackCache, dryRun, format); err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
 	}
 
 	// Print mapping
 	fmt.Println("Spotify playlist to Tidal playlist mapping")
 	for _, spotifyPlaylist := range spotifyPlaylists {
-		checkString := spotifyPlaylist.ID.String()
-		for _, tidtidalPlaylist := range tidalPlaylists {
-			if strings.Contains(tidtidalPlaylist.Data.Description, checkString) {
-				fmt.Printf("%s:%s\n", spotifyPlaylist.ID.String(), tidtidalPlaylist.Data.UUID)
-				break
-			}
+		if tidalUUID, ok := store.Get("spotify", spotifyPlaylist.ID, "tidal"); ok {
+			fmt.Printf("%s:%s\n", spotifyPlaylist.ID, tidalUUID)
 		}
 	}
 
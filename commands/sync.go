@@ -0,0 +1,355 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/matcher"
+	"github.com/zibbp/music-utils/navidrome"
+	"github.com/zibbp/music-utils/plan"
+	"github.com/zibbp/music-utils/playlistmap"
+	"github.com/zibbp/music-utils/provider"
+	"github.com/zibbp/music-utils/spotify"
+	"github.com/zibbp/music-utils/tidal"
+	"github.com/zibbp/music-utils/trackcache"
+)
+
+// Providers which can be used with sync
+var providerNames = []string{"spotify", "tidal", "navidrome", "navidrome-file"}
+
+func newProvider(name string, envConfig *config.Config, jsonConfig *config.JsonConfigService) (provider.Provider, error) {
+	switch name {
+	case "spotify":
+		spotifyClient, err := spotify.Initialize(envConfig.SpotifyClientId, envConfig.SpotifyClientSecret, envConfig.SpotifyRedirectUri, spotifyHttpClient(envConfig), jsonConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing spotify client: %v", err)
+		}
+		return spotify.NewProvider(spotifyClient), nil
+	case "tidal":
+		tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, envConfig.TidalCountryCode, tidalHttpClient(envConfig), jsonConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing tidal client: %v", err)
+		}
+		return tidal.NewProvider(tidalClient), nil
+	case "navidrome":
+		navidromeClient, err := newNavidromeClient(envConfig)
+		if err != nil {
+			return nil, err
+		}
+		return navidrome.NewProvider(navidromeClient), nil
+	case "navidrome-file":
+		return navidrome.NewFileProvider(navidrome.DefaultPath), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %s, must be one of: %s", name, strings.Join(providerNames, ", "))
+	}
+}
+
+// syncOptions controls how a destination playlist is made to match its source playlist
+type syncOptions struct {
+	// mirror removes destination tracks not in the source and matches the source order
+	mirror bool
+	// updateDetails sets the name and description of existing destination playlists to those of the source
+	updateDetails bool
+	// findPlaylist finds an existing destination playlist for a source playlist which is not mapped yet
+	findPlaylist func(sourcePlaylist provider.Playlist) (provider.Playlist, bool)
+}
+
+// Sync copies playlists from one provider to another. Destination playlists are found from the saved
+// playlist mappings, then by name, and created if missing. Only the playlists given by ID or name are synced,
+// or all when none are given. Tracks missing from the destination are added and, when mirror is set, tracks
+// not in the source are removed. With dryRun the plan is printed without changing anything.
+func Sync(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService, from string, to string, playlists []string, mirror bool, dryRun bool, format string) error {
+	if from == to {
+		return fmt.Errorf("source and destination provider must be different")
+	}
+
+	source, err := newProvider(from, envConfig, jsonConfig)
+	if err != nil {
+		return err
+	}
+	destination, err := newProvider(to, envConfig, jsonConfig)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("from", source.Name()).Str("to", destination.Name()).Msg("fetching playlists")
+
+	sourcePlaylists, err := source.ListPlaylists()
+	if err != nil {
+		return fmt.Errorf("error fetching %s playlists: %v", source.Name(), err)
+	}
+	destinationPlaylists, err := destination.ListPlaylists()
+	if err != nil {
+		return fmt.Errorf("error fetching %s playlists: %v", destination.Name(), err)
+	}
+
+	destinationByName := make(map[string]provider.Playlist)
+	for _, playlist := range destinationPlaylists {
+		destinationByName[strings.ToLower(playlist.Name)] = playlist
+	}
+
+	store, err := playlistmap.Load(playlistmap.DefaultPath)
+	if err != nil {
+		return fmt.Errorf("error loading playlist mappings: %v", err)
+	}
+
+	trackCache := openTrackCache(envConfig)
+	defer trackCache.Close()
+
+	p := plan.New("sync")
+	options := syncOptions{
+		mirror: mirror,
+		findPlaylist: func(sourcePlaylist provider.Playlist) (provider.Playlist, bool) {
+			playlist, ok := destinationByName[strings.ToLower(sourcePlaylist.Name)]
+			return playlist, ok
+		},
+	}
+
+	for _, sourcePlaylist := range sourcePlaylists {
+		if len(playlists) > 0 && !slices.ContainsFunc(playlists, func(playlist string) bool {
+			return playlist == sourcePlaylist.ID || strings.EqualFold(playlist, sourcePlaylist.Name)
+		}) {
+			continue
+		}
+
+		log.Info().Str("playlist", sourcePlaylist.Name).Msg("planning playlist sync")
+
+		destinationPlaylist, err := planPlaylistSync(p, source, destination, matcher.Default(), trackCache, store, sourcePlaylist, destinationPlaylists, options)
+		if err != nil {
+			log.Error().Err(err).Str("playlist", sourcePlaylist.Name).Msg("error planning playlist sync")
+			continue
+		}
+		// later playlists with the same name use the same destination
+		destinationByName[strings.ToLower(destinationPlaylist.Name)] = destinationPlaylist
+	}
+
+	return runPlan(p, map[string]provider.Provider{destination.Name(): destination}, store, trackCache, dryRun, format)
+}
+
+// planPlaylistSync adds the steps to make the destination playlist match the source playlist to the plan and
+// returns the destination playlist, which has a placeholder ID if it will be created.
+func planPlaylistSync(p *plan.Plan, source provider.Provider, destination provider.Provider, trackMatcher *matcher.Matcher, trackCache *trackcache.Cache, store *playlistmap.Store, sourcePlaylist provider.Playlist, destinationPlaylists []provider.Playlist, options syncOptions) (provider.Playlist, error) {
+	name := sourcePlaylist.Name
+	if name == "" {
+		name = "Untitled"
+	}
+
+	var destinationPlaylist provider.Playlist
+	found := false
+	if id, ok := store.Get(source.Name(), sourcePlaylist.ID, destination.Name()); ok {
+		index := slices.IndexFunc(destinationPlaylists, func(playlist provider.Playlist) bool { return playlist.ID == id })
+		if index != -1 {
+			destinationPlaylist = destinationPlaylists[index]
+			found = true
+		} else {
+			log.Warn().Str("playlist", sourcePlaylist.Name).Str("id", id).Msgf("mapped %s playlist no longer exists", destination.Name())
+		}
+	}
+	if !found && options.findPlaylist != nil {
+		if destinationPlaylist, found = options.findPlaylist(sourcePlaylist); found {
+			p.Add(plan.Step{
+				Action:           plan.LinkPlaylist,
+				Provider:         destination.Name(),
+				Playlist:         destinationPlaylist,
+				SourceProvider:   source.Name(),
+				SourcePlaylistID: sourcePlaylist.ID,
+			})
+		}
+	}
+
+	var destinationTracks []provider.Track
+	if found {
+		if options.updateDetails && (destinationPlaylist.Name != name || destinationPlaylist.Description != sourcePlaylist.Description) {
+			updatedPlaylist := destinationPlaylist
+			updatedPlaylist.Name = name
+			updatedPlaylist.Description = sourcePlaylist.Description
+			p.Add(plan.Step{
+				Action:              plan.UpdatePlaylist,
+				Provider:            destination.Name(),
+				Playlist:            updatedPlaylist,
+				PreviousName:        destinationPlaylist.Name,
+				PreviousDescription: destinationPlaylist.Description,
+			})
+			destinationPlaylist = updatedPlaylist
+		}
+
+		// playlists created earlier in the plan have no tracks yet
+		if !plan.IsNewPlaylist(destinationPlaylist.ID) {
+			var err error
+			destinationTracks, err = destination.GetTracks(destinationPlaylist.ID)
+			if err != nil {
+				return provider.Playlist{}, fmt.Errorf("error fetching %s tracks: %v", destination.Name(), err)
+			}
+		}
+	} else {
+		destinationPlaylist = provider.Playlist{
+			ID:          p.NewPlaylistID(),
+			Name:        name,
+			Description: sourcePlaylist.Description,
+		}
+		p.Add(plan.Step{
+			Action:           plan.CreatePlaylist,
+			Provider:         destination.Name(),
+			Playlist:         destinationPlaylist,
+			SourceProvider:   source.Name(),
+			SourcePlaylistID: sourcePlaylist.ID,
+		})
+	}
+
+	sourceTracks, err := source.GetTracks(sourcePlaylist.ID)
+	if err != nil {
+		return provider.Playlist{}, fmt.Errorf("error fetching %s tracks: %v", source.Name(), err)
+	}
+
+	matched, unmatched := matchTracks(source, destination, trackMatcher, trackCache, sourceTracks, destinationTracks)
+	planTrackChanges(p, destination.Name(), destinationPlaylist, destinationTracks, matched, options.mirror)
+
+	log.Info().Str("playlist", sourcePlaylist.Name).Int("matched", len(matched)).Int("unmatched", unmatched).Msg("planned playlist sync")
+
+	return destinationPlaylist, nil
+}
+
+// matchTracks finds each source track on the destination, preferring tracks already in the destination
+// playlist, then checking the track cache before searching. The matched tracks are returned in source order with the
+// number of tracks which could not be matched.
+func matchTracks(source provider.Provider, destination provider.Provider, trackMatcher *matcher.Matcher, trackCache *trackcache.Cache, sourceTracks []provider.Track, destinationTracks []provider.Track) ([]provider.Track, int) {
+	existing := make([]matcher.Track, 0, len(destinationTracks))
+	for _, track := range destinationTracks {
+		existing = append(existing, track.ToMatcherTrack())
+	}
+
+	// a destination without a catalog returns the source track from Search, caching it would map to the source's IDs
+	if !provider.HasCatalog(destination) {
+		trackCache = nil
+	}
+
+	matched := make([]provider.Track, 0, len(sourceTracks))
+	unmatched := 0
+	for _, sourceTrack := range sourceTracks {
+		var mapping *trackcache.Mapping
+		var destinationTrack provider.Track
+		if match := trackMatcher.Best(sourceTrack.ToMatcherTrack(), existing); match != nil {
+			destinationTrack = destinationTracks[match.Index]
+			mapping = newTrackMapping(source, destination, sourceTrack, destinationTrack, match)
+		} else {
+			cached, err := trackCache.Lookup(source.Name(), sourceTrack.ID, sourceTrack.ISRC, destination.Name())
+			if err != nil {
+				log.Debug().Err(err).Str("track", sourceTrack.Title).Msg("error looking up track cache")
+			}
+			if cached != nil {
+				// saved again to record when the mapping was last used
+				cached.VerifiedAt = time.Now().UTC()
+				if err := trackCache.Save(*cached); err != nil {
+					log.Warn().Err(err).Str("track", sourceTrack.Title).Msg("error saving track cache")
+				}
+
+				track := sourceTrack
+				track.ID = cached.DestinationID
+				matched = append(matched, track)
+				continue
+			}
+
+			candidates, err := destination.Search(sourceTrack)
+			if err != nil {
+				log.Error().Err(err).Str("track", sourceTrack.Title).Msgf("error searching %s", destination.Name())
+				unmatched++
+				continue
+			}
+
+			matcherTracks := make([]matcher.Track, 0, len(candidates))
+			for _, candidate := range candidates {
+				matcherTracks = append(matcherTracks, candidate.ToMatcherTrack())
+			}
+
+			match := trackMatcher.Best(sourceTrack.ToMatcherTrack(), matcherTracks)
+			if match == nil {
+				log.Warn().Str("track", sourceTrack.Title).Strs("artists", sourceTrack.Artists).Msgf("no match found on %s", destination.Name())
+				unmatched++
+				continue
+			}
+			destinationTrack = candidates[match.Index]
+			mapping = newTrackMapping(source, destination, sourceTrack, destinationTrack, match)
+		}
+
+		if err := trackCache.Save(*mapping); err != nil {
+			log.Warn().Err(err).Str("track", sourceTrack.Title).Msg("error saving track cache")
+		}
+		matched = append(matched, destinationTrack)
+	}
+
+	return matched, unmatched
+}
+
+// planTrackChanges adds the steps to add the wanted tracks missing from the playlist. With mirror, tracks
+// which are not wanted are removed and the playlist is reordered if it does not end up in the wanted order.
+func planTrackChanges(p *plan.Plan, providerName string, playlist provider.Playlist, current []provider.Track, wanted []provider.Track, mirror bool) {
+	// compare by ID counting duplicates
+	count := make(map[string]int)
+	for _, track := range current {
+		count[track.ID]++
+	}
+	var add []provider.Track
+	for _, track := range wanted {
+		if count[track.ID] > 0 {
+			count[track.ID]--
+			continue
+		}
+		add = append(add, track)
+	}
+
+	var remove []provider.Track
+	var kept []string
+	if mirror {
+		count = make(map[string]int)
+		for _, track := range wanted {
+			count[track.ID]++
+		}
+		for _, track := range current {
+			if count[track.ID] > 0 {
+				count[track.ID]--
+				kept = append(kept, track.ID)
+				continue
+			}
+			remove = append(remove, track)
+		}
+	}
+
+	if len(remove) > 0 {
+		p.Add(plan.Step{Action: plan.RemoveTracks, Provider: providerName, Playlist: playlist, Tracks: remove})
+	}
+	if len(add) > 0 {
+		p.Add(plan.Step{Action: plan.AddTracks, Provider: providerName, Playlist: playlist, Tracks: add})
+	}
+
+	if !mirror {
+		return
+	}
+
+	order := make([]string, 0, len(wanted))
+	for _, track := range wanted {
+		order = append(order, track.ID)
+	}
+	for _, track := range add {
+		kept = append(kept, track.ID)
+	}
+	if !slices.Equal(kept, order) {
+		p.Add(plan.Step{Action: plan.ReorderTracks, Provider: providerName, Playlist: playlist, Order: order})
+	}
+}
+
+func newTrackMapping(source provider.Provider, destination provider.Provider, sourceTrack provider.Track, destinationTrack provider.Track, match *matcher.Match) *trackcache.Mapping {
+	return &trackcache.Mapping{
+		SourceProvider:      source.Name(),
+		SourceID:            sourceTrack.ID,
+		DestinationProvider: destination.Name(),
+		DestinationID:       destinationTrack.ID,
+		ISRC:                sourceTrack.ISRC,
+		Confidence:          match.Confidence,
+		Method:              string(match.Method),
+	}
+}
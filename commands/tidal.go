@@ -2,20 +2,41 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/history"
+	"github.com/zibbp/music-utils/plan"
+	"github.com/zibbp/music-utils/playlistmap"
+	"github.com/zibbp/music-utils/provider"
 	"github.com/zibbp/music-utils/tidal"
 	"github.com/zibbp/music-utils/utils"
 )
 
-func SaveTidalPlaylists(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService, saveNavidromeFormat bool) error {
+// tidalPlaylistState is the last saved version of a playlist, used to skip unchanged playlists
+type tidalPlaylistState struct {
+	Title           string    `json:"title"`
+	LastUpdated     string    `json:"last_updated"`
+	LastItemAddedAt string    `json:"last_item_added_at"`
+	SavedAt         time.Time `json:"saved_at"`
+	// When the playlist was first found missing from Tidal, its last backup is kept
+	RemovedAt *time.Time `json:"removed_at,omitempty"`
+}
+
+// SaveTidalPlaylists saves the user's playlists. Playlists which have not been updated since the
+// last save are skipped unless full is set. Playlists which no longer exist are reported.
+func SaveTidalPlaylists(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService, saveNavidromeFormat bool, full bool) error {
 	// Initialize Tidal client
-	tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, jsonConfig)
+	tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, envConfig.TidalCountryCode, tidalHttpClient(envConfig), jsonConfig)
 	if err != nil {
 		return fmt.Errorf("error initializing tidal client: %v", err)
 	}
@@ -28,26 +49,69 @@ func SaveTidalPlaylists(ctx context.Context, envConfig *config.Config, jsonConfi
 		return err
 	}
 
+	playlistStates := make(map[string]tidalPlaylistState)
+	if err := utils.ReadJsonFromFile("/data/state", "tidal_playlists", &playlistStates); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Msg("error reading tidal playlist state, saving all playlists")
+	}
+
+	// report playlists which were saved before but no longer exist on every run, their backups are orphaned
+	current := make(map[string]bool, len(tidalPlaylists))
+	for _, tidalPlaylist := range tidalPlaylists {
+		current[tidalPlaylist.Data.UUID] = true
+	}
+	for uuid, state := range playlistStates {
+		if current[uuid] {
+			continue
+		}
+		if state.RemovedAt == nil {
+			removedAt := time.Now()
+			state.RemovedAt = &removedAt
+			playlistStates[uuid] = state
+		}
+		log.Warn().Str("playlist", state.Title).Str("uuid", uuid).Time("removed_at", *state.RemovedAt).Msg("playlist no longer exists on tidal, keeping last backup")
+	}
+
+	skipped := 0
 	for _, tmpTidalPlaylist := range tidalPlaylists {
+		uuid := tmpTidalPlaylist.Data.UUID
+
+		state, ok := playlistStates[uuid]
+		unchanged := ok && state.LastUpdated == tmpTidalPlaylist.Data.LastUpdated && state.LastItemAddedAt == tmpTidalPlaylist.Data.LastItemAddedAt
+		if !full && unchanged && utils.JsonFileExists("/data/tidal", uuid) && (!saveNavidromeFormat || utils.JsonFileExists("/data/navidrome", uuid+"_navidrome")) {
+			log.Debug().Str("playlist", tmpTidalPlaylist.Data.Title).Msg("playlist unchanged, skipping")
+			skipped++
+			continue
+		}
+
 		log.Info().Str("playlist", tmpTidalPlaylist.Data.Title).Msg("processing playlist")
 		// Get full playlist
 		tidalPlaylist, err := tidalClient.GetPlaylist(string(tmpTidalPlaylist.Data.UUID))
 		if err != nil {
 			log.Error().Err(err).Msg("error getting tidal playlist")
+			continue
 		}
 
 		// Get playlist tracks
 		tidalPlaylistTracks, err := tidalClient.GetPlaylistTracks(string(tidalPlaylist.UUID))
 		if err != nil {
 			log.Error().Err(err).Msg("error getting tidal playlist tracks")
+			continue
 		}
 		tidalPlaylist.Tracks = append(tidalPlaylist.Tracks, tidalPlaylistTracks.Items...)
 
+		if int64(len(tidalPlaylist.Tracks)) != tidalPlaylist.NumberOfTracks {
+			log.Warn().Str("playlist", tidalPlaylist.Title).Int("tracks", len(tidalPlaylist.Tracks)).Int64("number_of_tracks", tidalPlaylist.NumberOfTracks).Msg("saved track count does not match playlist track count")
+		}
+
 		if err := utils.WriteJsonToFile("/data/tidal", tidalPlaylist.UUID, tidalPlaylist); err != nil {
 			log.Error().Err(err).Msg("error writing playlist to file")
 			continue
 		}
 
+		if err := history.Save("tidal", tidalPlaylist.UUID, tidalPlaylist, envConfig.HistoryRetention); err != nil {
+			log.Error().Err(err).Msg("error writing playlist snapshot")
+		}
+
 		if saveNavidromeFormat {
 			navidromePlaylist, err := tidalClient.ToNavidromePlaylist(tidalPlaylist)
 			if err != nil {
@@ -59,14 +123,196 @@ func SaveTidalPlaylists(ctx context.Context, envConfig *config.Config, jsonConfi
 				continue
 			}
 		}
+
+		playlistStates[uuid] = tidalPlaylistState{
+			Title:           tmpTidalPlaylist.Data.Title,
+			LastUpdated:     tmpTidalPlaylist.Data.LastUpdated,
+			LastItemAddedAt: tmpTidalPlaylist.Data.LastItemAddedAt,
+			SavedAt:         time.Now(),
+		}
+	}
+
+	log.Info().Int("skipped", skipped).Int("total", len(tidalPlaylists)).Msg("saved tidal playlists")
+
+	if err := utils.WriteJsonToFile("/data/state", "tidal_playlists", playlistStates); err != nil {
+		return fmt.Errorf("error writing tidal playlist state: %v", err)
+	}
+
+	return nil
+}
+
+func SaveTidalFavorites(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService) error {
+	// Initialize Tidal client
+	tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, envConfig.TidalCountryCode, tidalHttpClient(envConfig), jsonConfig)
+	if err != nil {
+		return fmt.Errorf("error initializing tidal client: %v", err)
+	}
+
+	log.Info().Msg("fetching tidal favorites")
+
+	favoriteTracks, err := tidalClient.GetFavoriteTracks()
+	if err != nil {
+		return fmt.Errorf("error getting tidal favorite tracks: %v", err)
+	}
+	if err := utils.WriteJsonToFile("/data/tidal/favorites", "tracks", favoriteTracks); err != nil {
+		return fmt.Errorf("error writing favorite tracks to file: %v", err)
+	}
+	log.Info().Int("count", len(favoriteTracks)).Msg("saved favorite tracks")
+
+	favoriteAlbums, err := tidalClient.GetFavoriteAlbums()
+	if err != nil {
+		return fmt.Errorf("error getting tidal favorite albums: %v", err)
+	}
+	if err := utils.WriteJsonToFile("/data/tidal/favorites", "albums", favoriteAlbums); err != nil {
+		return fmt.Errorf("error writing favorite albums to file: %v", err)
+	}
+	log.Info().Int("count", len(favoriteAlbums)).Msg("saved favorite albums")
+
+	favoriteArtists, err := tidalClient.GetFavoriteArtists()
+	if err != nil {
+		return fmt.Errorf("error getting tidal favorite artists: %v", err)
+	}
+	if err := utils.WriteJsonToFile("/data/tidal/favorites", "artists", favoriteArtists); err != nil {
+		return fmt.Errorf("error writing favorite artists to file: %v", err)
 	}
+	log.Info().Int("count", len(favoriteArtists)).Msg("saved favorite artists")
+
+	favoriteMixes, err := tidalClient.GetFavoriteMixes()
+	if err != nil {
+		return fmt.Errorf("error getting tidal favorite mixes: %v", err)
+	}
+	if err := utils.WriteJsonToFile("/data/tidal/favorites", "mixes", favoriteMixes); err != nil {
+		return fmt.Errorf("error writing favorite mixes to file: %v", err)
+	}
+	log.Info().Int("count", len(favoriteMixes)).Msg("saved favorite mixes")
 
 	return nil
 }
 
+// RestoreTidalPlaylists recreates the playlists saved at path, either a single saved playlist or a directory of them.
+// Playlists which still exist are updated to match the backup, others are created and recorded in the playlist
+// mappings so restoring again updates the created playlist. Tracks which are no longer
+// available are reported when the plan is applied. With dryRun the plan is printed without modifying anything.
+func RestoreTidalPlaylists(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService, path string, dryRun bool, format string) error {
+	playlists, err := loadTidalBackups(path)
+	if err != nil {
+		return err
+	}
+	if len(playlists) == 0 {
+		return fmt.Errorf("no saved tidal playlists found at %s", path)
+	}
+
+	// Initialize Tidal client
+	tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, envConfig.TidalCountryCode, tidalHttpClient(envConfig), jsonConfig)
+	if err != nil {
+		return fmt.Errorf("error initializing tidal client: %v", err)
+	}
+	tidalProvider := tidal.NewProvider(tidalClient)
+
+	store, err := playlistmap.Load(playlistmap.DefaultPath)
+	if err != nil {
+		return fmt.Errorf("error loading playlist mappings: %v", err)
+	}
+
+	p := plan.New("tidal restore")
+
+	for _, savedPlaylist := range playlists {
+		tracks := make([]provider.Track, 0, len(savedPlaylist.Tracks))
+		for _, track := range savedPlaylist.Tracks {
+			tracks = append(tracks, provider.FromMatcherTrack(track.ToMatcherTrack()))
+		}
+
+		// a playlist restored before is found by the mapping from the saved playlist to the playlist created for it
+		uuid := savedPlaylist.UUID
+		if mapped, ok := store.Get("tidal", savedPlaylist.UUID, "tidal"); ok {
+			uuid = mapped
+		}
+
+		restored := provider.Playlist{
+			ID:          uuid,
+			Name:        savedPlaylist.Title,
+			Description: savedPlaylist.Description,
+		}
+
+		existing, err := tidalClient.GetPlaylist(uuid)
+		switch {
+		case errors.Is(err, tidal.ErrNotFound):
+			log.Debug().Err(err).Str("uuid", uuid).Msg("saved playlist not found on tidal")
+
+			restored.ID = p.NewPlaylistID()
+			p.Add(plan.Step{
+				Action:           plan.CreatePlaylist,
+				Provider:         "tidal",
+				Playlist:         restored,
+				SourceProvider:   "tidal",
+				SourcePlaylistID: savedPlaylist.UUID,
+			})
+			planTrackChanges(p, "tidal", restored, nil, tracks, true)
+		case err != nil:
+			return fmt.Errorf("error fetching tidal playlist %s: %v", savedPlaylist.Title, err)
+		default:
+			if existing.Title != savedPlaylist.Title || existing.Description != savedPlaylist.Description {
+				p.Add(plan.Step{
+					Action:              plan.UpdatePlaylist,
+					Provider:            "tidal",
+					Playlist:            restored,
+					PreviousName:        existing.Title,
+					PreviousDescription: existing.Description,
+				})
+			}
+
+			current, err := tidalProvider.GetTracks(uuid)
+			if err != nil {
+				log.Error().Err(err).Str("playlist", savedPlaylist.Title).Msg("error getting tidal playlist tracks")
+				continue
+			}
+			planTrackChanges(p, "tidal", restored, current, tracks, true)
+		}
+
+		log.Info().Str("playlist", savedPlaylist.Title).Int("tracks", len(tracks)).Msg("planned playlist restore")
+	}
+
+	return runPlan(p, map[string]provider.Provider{"tidal": tidalProvider}, store, nil, dryRun, format)
+}
+
+// loadTidalBackups reads a saved playlist file or every saved playlist in a directory
+func loadTidalBackups(path string) ([]*tidal.Playlist, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var playlists []*tidal.Playlist
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var playlist tidal.Playlist
+		if err := json.Unmarshal(data, &playlist); err != nil || playlist.UUID == "" {
+			// other files such as the navidrome format are skipped
+			log.Debug().Str("file", file).Msg("not a saved tidal playlist, skipping")
+			continue
+		}
+
+		playlists = append(playlists, &playlist)
+	}
+
+	return playlists, nil
+}
+
 func PrintTidalPlaylists(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService) error {
 	// Initialize Tidal client
-	tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, jsonConfig)
+	tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, envConfig.TidalCountryCode, tidalHttpClient(envConfig), jsonConfig)
 	if err != nil {
 		return fmt.Errorf("error initializing tidal client: %v", err)
 	}
@@ -97,7 +343,7 @@ func PrintTidalPlaylists(ctx context.Context, envConfig *config.Config, jsonConf
 
 func PrintTidalPlaylistsLinks(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService) error {
 	// Initialize Tidal client
-	tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, jsonConfig)
+	tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, envConfig.TidalCountryCode, tidalHttpClient(envConfig), jsonConfig)
 	if err != nil {
 		return fmt.Errorf("error initializing tidal client: %v", err)
 	}
@@ -114,3 +360,88 @@ func PrintTidalPlaylistsLinks(ctx context.Context, envConfig *config.Config, jso
 
 	return nil
 }
+
+func SearchTidal(ctx context.Context, envConfig *config.Config, jsonConfig *config.JsonConfigService, query string, searchType string, isrc string, limit int, offset int) error {
+	// Initialize Tidal client
+	tidalClient, err := tidal.Initialize(envConfig.TidalClientId, envConfig.TidalClientSecret, envConfig.TidalCountryCode, tidalHttpClient(envConfig), jsonConfig)
+	if err != nil {
+		return fmt.Errorf("error initializing tidal client: %v", err)
+	}
+
+	var data [][]string
+
+	if isrc != "" {
+		tracks, err := tidalClient.GetTracksByISRC(isrc)
+		if err != nil {
+			return fmt.Errorf("error looking up isrc: %v", err)
+		}
+		data = tidalTrackRows(tracks)
+	} else {
+		switch searchType {
+		case "tracks":
+			results, err := tidalClient.SearchTracks(query, limit, offset)
+			if err != nil {
+				return err
+			}
+			data = tidalTrackRows(results.Items)
+		case "albums":
+			results, err := tidalClient.SearchAlbums(query, limit, offset)
+			if err != nil {
+				return err
+			}
+			data = [][]string{{"ID", "Title", "Artist", "Release Date"}}
+			for _, album := range results.Items {
+				artist := ""
+				if len(album.Artists) > 0 {
+					artist = album.Artists[0].Name
+				}
+				data = append(data, []string{strconv.FormatInt(album.ID, 10), album.Title, artist, album.ReleaseDate})
+			}
+		case "artists":
+			results, err := tidalClient.SearchArtists(query, limit, offset)
+			if err != nil {
+				return err
+			}
+			data = [][]string{{"ID", "Name"}}
+			for _, artist := range results.Items {
+				data = append(data, []string{strconv.FormatInt(artist.ID, 10), artist.Name})
+			}
+		case "playlists":
+			results, err := tidalClient.SearchPlaylists(query, limit, offset)
+			if err != nil {
+				return err
+			}
+			data = [][]string{{"ID", "Title", "Tracks"}}
+			for _, playlist := range results.Items {
+				data = append(data, []string{playlist.UUID, playlist.Title, strconv.FormatInt(playlist.NumberOfTracks, 10)})
+			}
+		default:
+			return fmt.Errorf("unknown search type: %s", searchType)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	for _, row := range data {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	w.Flush()
+
+	return nil
+}
+
+func tidalTrackRows(tracks []tidal.Track) [][]string {
+	data := [][]string{
+		{"ID", "Title", "Artist", "Album", "ISRC"},
+	}
+
+	for _, track := range tracks {
+		artist := ""
+		if len(track.Artists) > 0 {
+			artist = track.Artists[0].Name
+		}
+		data = append(data, []string{strconv.FormatInt(track.ID, 10), track.Title, artist, track.Album.Title, track.Isrc})
+	}
+
+	return data
+}
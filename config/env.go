@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
 )
@@ -12,9 +13,25 @@ type Config struct {
 	JSONLog             bool   `env:"JSON_LOG, default=false"`
 	TidalClientId       string `env:"TIDAL_CLIENT_ID"`
 	TidalClientSecret   string `env:"TIDAL_CLIENT_SECRET"`
+	TidalCountryCode    string `env:"TIDAL_COUNTRY_CODE"`
 	SpotifyClientId     string `env:"SPOTIFY_CLIENT_ID"`
 	SpotifyClientSecret string `env:"SPOTIFY_CLIENT_SECRET"`
 	SpotifyRedirectUri  string `env:"SPOTIFY_CLIENT_REDIRECT_URI, default=http://localhost:28542/callback"`
+	NavidromeUrl        string `env:"NAVIDROME_URL"`
+	NavidromeUsername   string `env:"NAVIDROME_USERNAME"`
+	NavidromePassword   string `env:"NAVIDROME_PASSWORD"`
+	// HTTP client settings shared by all providers
+	HttpTimeout                time.Duration `env:"HTTP_TIMEOUT, default=2m"`
+	HttpMaxRetries             int           `env:"HTTP_MAX_RETRIES, default=5"`
+	TidalRequestsPerSecond     float64       `env:"TIDAL_REQUESTS_PER_SECOND, default=5"`
+	SpotifyRequestsPerSecond   float64       `env:"SPOTIFY_REQUESTS_PER_SECOND, default=10"`
+	NavidromeRequestsPerSecond float64       `env:"NAVIDROME_REQUESTS_PER_SECOND, default=20"`
+	// Number of snapshots kept per playlist, 0 keeps all
+	HistoryRetention int `env:"HISTORY_RETENTION, default=30"`
+	// SQLite database mapping tracks between providers
+	TrackCachePath string `env:"TRACK_CACHE_PATH, default=/data/tracks.db"`
+	// Tags of the local music files read by local index
+	LibraryIndexPath string `env:"LIBRARY_INDEX_PATH, default=/data/state/library_index.json"`
 }
 
 func Init() (*Config, error) {
@@ -18,12 +18,15 @@ type SpotifyConfig struct {
 	RefreshToken string    `json:"refresh_token"`
 	Expiry       time.Time `json:"expiry"`
 	TokenType    string    `json:"token_type"`
+	Scopes       []string  `json:"scopes"`
 }
 
 type TidalConfig struct {
-	UserID       string `json:"user_id"`
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	UserID       string    `json:"user_id"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry"`
+	CountryCode  string    `json:"country_code"`
 }
 
 type JsonConfigService struct {
@@ -0,0 +1,166 @@
+// Package history keeps timestamped snapshots of saved playlists.
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zibbp/music-utils/utils"
+)
+
+const (
+	historyPath = "/data/history"
+	// snapshot file names sort chronologically
+	timeLayout = "20060102T150405Z"
+)
+
+type Snapshot struct {
+	Provider string
+	ID       string
+	Time     time.Time
+	Name     string
+}
+
+func (s Snapshot) dir() string {
+	return filepath.Join(historyPath, s.Provider, s.ID)
+}
+
+// Load reads the snapshot into data
+func (s Snapshot) Load(data interface{}) error {
+	return utils.ReadJsonFromFile(s.dir(), s.Name, data)
+}
+
+// Save writes a new snapshot of the playlist and removes the oldest snapshots so at most
+// retention are kept. A retention of 0 keeps all snapshots.
+func Save(provider string, id string, data interface{}, retention int) error {
+	snapshot := Snapshot{
+		Provider: provider,
+		ID:       id,
+		Time:     time.Now().UTC(),
+	}
+	snapshot.Name = snapshot.Time.Format(timeLayout)
+
+	if err := utils.WriteJsonToFile(snapshot.dir(), snapshot.Name, data); err != nil {
+		return err
+	}
+
+	if retention <= 0 {
+		return nil
+	}
+
+	snapshots, err := List(provider, id)
+	if err != nil {
+		return err
+	}
+
+	for len(snapshots) > retention {
+		if err := os.Remove(filepath.Join(snapshots[0].dir(), snapshots[0].Name+".json")); err != nil {
+			return fmt.Errorf("error removing snapshot: %w", err)
+		}
+		snapshots = snapshots[1:]
+	}
+
+	return nil
+}
+
+// List returns the snapshots of the playlist from oldest to newest
+func List(provider string, id string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(historyPath, provider, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		t, err := time.Parse(timeLayout, name)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{
+			Provider: provider,
+			ID:       id,
+			Time:     t,
+			Name:     name,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+
+	return snapshots, nil
+}
+
+// Providers returns the providers which have snapshots of the playlist
+func Providers(id string) []string {
+	entries, err := os.ReadDir(historyPath)
+	if err != nil {
+		return nil
+	}
+
+	var providers []string
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(historyPath, entry.Name(), id)); err == nil {
+			providers = append(providers, entry.Name())
+		}
+	}
+
+	return providers
+}
+
+// Track is a playlist entry compared between snapshots
+type Track struct {
+	ID      string
+	Title   string
+	Artist  string
+	AddedAt string
+	AddedBy string
+}
+
+func (t Track) String() string {
+	if t.Artist == "" {
+		return t.Title
+	}
+	return fmt.Sprintf("%s - %s", t.Artist, t.Title)
+}
+
+// Diff returns the tracks added and removed between two versions of a playlist.
+// Tracks are compared by ID and duplicates are counted.
+func Diff(from []Track, to []Track) (added []Track, removed []Track) {
+	count := make(map[string]int)
+	for _, track := range from {
+		count[track.ID]++
+	}
+	for _, track := range to {
+		if count[track.ID] > 0 {
+			count[track.ID]--
+			continue
+		}
+		added = append(added, track)
+	}
+
+	count = make(map[string]int)
+	for _, track := range to {
+		count[track.ID]++
+	}
+	for _, track := range from {
+		if count[track.ID] > 0 {
+			count[track.ID]--
+			continue
+		}
+		removed = append(removed, track)
+	}
+
+	return added, removed
+}
@@ -0,0 +1,228 @@
+// Package httpclient provides the HTTP client shared by the provider packages.
+// Requests are rate limited per provider and retried with exponential backoff on
+// throttling, server errors and network errors. Requests which are not idempotent,
+// such as adding tracks, are only retried when they were throttled or never sent.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type Options struct {
+	// Name of the provider, used in logs
+	Name string
+	// Timeout of a request made with the client returned by New. Retries happen inside the
+	// transport so the timeout covers all attempts and the backoff between them. A throttled
+	// request asked to wait longer than the timeout is not retried.
+	Timeout time.Duration
+	// Maximum number of retries of a failed request
+	MaxRetries int
+	// Initial backoff, doubled for each retry
+	MinBackoff time.Duration
+	// Maximum backoff between retries
+	MaxBackoff time.Duration
+	// Request budget of the provider, 0 disables rate limiting
+	RequestsPerSecond float64
+}
+
+// Transport is an http.RoundTripper which rate limits and retries requests
+type Transport struct {
+	Base    http.RoundTripper
+	options Options
+
+	mu          sync.Mutex
+	nextRequest time.Time
+}
+
+// base is shared by all clients so connections are pooled
+var base = http.DefaultTransport.(*http.Transport).Clone()
+
+// New returns an HTTP client using a Transport configured with the options
+func New(options Options) *http.Client {
+	return &http.Client{
+		Timeout:   options.Timeout,
+		Transport: NewTransport(options),
+	}
+}
+
+func NewTransport(options Options) *Transport {
+	if options.MinBackoff == 0 {
+		options.MinBackoff = 500 * time.Millisecond
+	}
+	if options.MaxBackoff == 0 {
+		options.MaxBackoff = 30 * time.Second
+	}
+
+	return &Transport{
+		Base:    base,
+		options: options,
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(req); err != nil {
+			return nil, err
+		}
+
+		// replay the body for retries
+		r := req
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("request body of %s cannot be replayed", req.URL.Path)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+
+		resp, err := t.Base.RoundTrip(r)
+
+		if attempt >= t.options.MaxRetries || !retryable(req, resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				if retryAfter > t.maxRetryAfter() {
+					log.Warn().Str("provider", t.options.Name).Str("url", req.URL.Path).Dur("retry_after", retryAfter).Msg("retry after is too long, not retrying")
+					return resp, err
+				}
+				delay = retryAfter
+			}
+			// drain so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		}
+
+		log.Debug().Str("provider", t.options.Name).Str("url", req.URL.Path).Int("attempt", attempt+1).Dur("delay", delay).Err(err).Msg("retrying request")
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// wait blocks until the provider's request budget allows another request
+func (t *Transport) wait(req *http.Request) error {
+	if t.options.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	interval := time.Duration(float64(time.Second) / t.options.RequestsPerSecond)
+
+	t.mu.Lock()
+	now := time.Now()
+	next := t.nextRequest
+	if next.Before(now) {
+		next = now
+	}
+	t.nextRequest = next.Add(interval)
+	t.mu.Unlock()
+
+	delay := time.Until(next)
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// maxRetryAfter is the longest Retry-After which is waited for. A longer wait would exceed the timeout,
+// without a timeout the maximum backoff is used.
+func (t *Transport) maxRetryAfter() time.Duration {
+	if t.options.Timeout > 0 {
+		return t.options.Timeout
+	}
+	return t.options.MaxBackoff
+}
+
+// backoff returns the exponential backoff with full jitter for the attempt
+func (t *Transport) backoff(attempt int) time.Duration {
+	backoff := t.options.MinBackoff << attempt
+	if backoff <= 0 || backoff > t.options.MaxBackoff {
+		backoff = t.options.MaxBackoff
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// retryable reports whether the request can be sent again. A request which is not idempotent may have
+// been applied by the server before a network error or server error, so it is only retried when it was
+// throttled or could not be sent at all.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	idempotent := isIdempotent(req.Method) && req.Context().Value(notIdempotentKey{}) == nil
+	if err != nil {
+		return idempotent || notSent(err)
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || (idempotent && resp.StatusCode >= 500)
+}
+
+type notIdempotentKey struct{}
+
+// NotIdempotent marks the requests made with the context as not idempotent whatever their method,
+// e.g. a PUT which creates a playlist
+func NotIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, notIdempotentKey{}, true)
+}
+
+// isIdempotent reports whether sending the request twice has the same effect as sending it once. DELETE
+// is not included as the providers remove playlist items by position.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut:
+		return true
+	}
+	return false
+}
+
+// notSent reports whether the error happened before the request reached the server
+func notSent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// parseRetryAfter parses the Retry-After header as either seconds or an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,208 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		status        int
+		notIdempotent bool
+		wantAttempts  int32
+	}{
+		{name: "get server error", method: http.MethodGet, status: http.StatusInternalServerError, wantAttempts: 3},
+		{name: "put server error", method: http.MethodPut, status: http.StatusBadGateway, wantAttempts: 3},
+		{name: "post server error", method: http.MethodPost, status: http.StatusInternalServerError, wantAttempts: 1},
+		{name: "delete server error", method: http.MethodDelete, status: http.StatusServiceUnavailable, wantAttempts: 1},
+		{name: "not idempotent put server error", method: http.MethodPut, status: http.StatusInternalServerError, notIdempotent: true, wantAttempts: 1},
+		{name: "get throttled", method: http.MethodGet, status: http.StatusTooManyRequests, wantAttempts: 3},
+		{name: "post throttled", method: http.MethodPost, status: http.StatusTooManyRequests, wantAttempts: 3},
+		{name: "not idempotent put throttled", method: http.MethodPut, status: http.StatusTooManyRequests, notIdempotent: true, wantAttempts: 3},
+		{name: "get client error", method: http.MethodGet, status: http.StatusNotFound, wantAttempts: 1},
+		{name: "get ok", method: http.MethodGet, status: http.StatusOK, wantAttempts: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				// the body is sent again with each retry
+				if body, _ := io.ReadAll(r.Body); r.Method != http.MethodGet && string(body) != "body" {
+					t.Errorf("attempt %d body = %q", attempts.Load(), body)
+				}
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			ctx := context.Background()
+			if test.notIdempotent {
+				ctx = NotIdempotent(ctx)
+			}
+			var body io.Reader
+			if test.method != http.MethodGet {
+				body = strings.NewReader("body")
+			}
+			req, err := http.NewRequestWithContext(ctx, test.method, server.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := testClient(0).Do(req)
+			if err != nil {
+				t.Fatalf("error sending request: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != test.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, test.status)
+			}
+			if got := attempts.Load(); got != test.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, test.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryAfter   string
+		timeout      time.Duration
+		wantAttempts int32
+	}{
+		{name: "waits", retryAfter: "0", wantAttempts: 3},
+		{name: "longer than the timeout", retryAfter: "3600", timeout: time.Second, wantAttempts: 1},
+		{name: "longer than the maximum backoff", retryAfter: "3600", wantAttempts: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.Header().Set("Retry-After", test.retryAfter)
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer server.Close()
+
+			start := time.Now()
+			resp, err := testClient(test.timeout).Get(server.URL)
+			if err != nil {
+				t.Fatalf("error sending request: %v", err)
+			}
+			resp.Body.Close()
+
+			if got := attempts.Load(); got != test.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, test.wantAttempts)
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+
+	for _, test := range tests {
+		got, ok := parseRetryAfter(test.value)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %s, %t, want %s, %t", test.value, got, ok, test.want, test.wantOK)
+		}
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got, ok := parseRetryAfter(date); !ok || got <= 55*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %s, %t, want about a minute", date, got, ok)
+	}
+}
+
+func TestRetryableErrors(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+
+	tests := []struct {
+		name          string
+		method        string
+		notIdempotent bool
+		err           error
+		want          bool
+	}{
+		{name: "get read error", method: http.MethodGet, err: readErr, want: true},
+		{name: "post read error", method: http.MethodPost, err: readErr, want: false},
+		{name: "post dial error", method: http.MethodPost, err: dialErr, want: true},
+		{name: "post dns error", method: http.MethodPost, err: &net.DNSError{Err: "no such host"}, want: true},
+		{name: "not idempotent put read error", method: http.MethodPut, notIdempotent: true, err: readErr, want: false},
+		{name: "not idempotent put dial error", method: http.MethodPut, notIdempotent: true, err: dialErr, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.notIdempotent {
+				ctx = NotIdempotent(ctx)
+			}
+			req, err := http.NewRequestWithContext(ctx, test.method, "http://example.com", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := retryable(req, nil, test.err); got != test.want {
+				t.Errorf("retryable = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := New(Options{Name: "test", RequestsPerSecond: 50})
+
+	start := time.Now()
+	for range 5 {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("error sending request: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// the first request is sent immediately, then one every 20ms
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("5 requests took %s, want at least 80ms", elapsed)
+	}
+}
+
+func testClient(timeout time.Duration) *http.Client {
+	return New(Options{
+		Name:       "test",
+		Timeout:    timeout,
+		MaxRetries: 2,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+	})
+}
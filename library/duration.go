@@ -0,0 +1,318 @@
+package library
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+// readDuration reads the duration from the stream headers of the file. The tags don't have it.
+func readDuration(r io.ReadSeeker, fileType tag.FileType) (time.Duration, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	switch fileType {
+	case tag.FLAC:
+		start, err := skipID3v2(r)
+		if err != nil {
+			return 0, err
+		}
+		return flacDuration(r, start)
+	case tag.MP3:
+		start, err := skipID3v2(r)
+		if err != nil {
+			return 0, err
+		}
+		return mp3Duration(r, start, size)
+	case tag.M4A, tag.M4B, tag.M4P, tag.ALAC:
+		return mp4Duration(r, 0, size)
+	case tag.OGG:
+		return oggDuration(r, size)
+	}
+
+	return 0, fmt.Errorf("unsupported file type %s", fileType)
+}
+
+func readAt(r io.ReadSeeker, offset int64, n int) ([]byte, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	n, err := io.ReadFull(r, b)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return b[:n], nil
+}
+
+// skipID3v2 returns the offset of the audio data after an ID3v2 tag at the start of the file
+func skipID3v2(r io.ReadSeeker) (int64, error) {
+	header, err := readAt(r, 0, 10)
+	if err != nil {
+		return 0, err
+	}
+	if len(header) < 10 || string(header[:3]) != "ID3" {
+		return 0, nil
+	}
+
+	// the size is a syncsafe integer of 7 bits per byte
+	size := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+	offset := 10 + size
+	if header[5]&0x10 != 0 {
+		// footer
+		offset += 10
+	}
+	return offset, nil
+}
+
+func flacDuration(r io.ReadSeeker, start int64) (time.Duration, error) {
+	// "fLaC", the block header then the STREAMINFO block which is always first
+	b, err := readAt(r, start, 4+4+34)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) < 42 || string(b[:4]) != "fLaC" || b[4]&0x7F != 0 {
+		return 0, fmt.Errorf("missing flac stream info")
+	}
+
+	info := b[8:]
+	sampleRate := int64(info[10])<<12 | int64(info[11])<<4 | int64(info[12])>>4
+	samples := int64(info[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(info[14:18]))
+	if sampleRate == 0 {
+		return 0, fmt.Errorf("invalid flac sample rate")
+	}
+
+	return time.Duration(samples) * time.Second / time.Duration(sampleRate), nil
+}
+
+var (
+	mp3Bitrates = map[bool][]int64{
+		// MPEG 1
+		true: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		// MPEG 2 and 2.5
+		false: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	mp3SampleRates = []int64{44100, 48000, 32000}
+)
+
+// mp3Duration reads the frame count from a Xing or VBRI header and falls back to the bitrate of the
+// first frame for constant bitrate files
+func mp3Duration(r io.ReadSeeker, start int64, size int64) (time.Duration, error) {
+	b, err := readAt(r, start, 64*1024)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i+4 <= len(b); i++ {
+		if b[i] != 0xFF || b[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+
+		version := (b[i+1] >> 3) & 0x03
+		layer := (b[i+1] >> 1) & 0x03
+		bitrateIndex := b[i+2] >> 4
+		sampleRateIndex := (b[i+2] >> 2) & 0x03
+		// only layer III is supported
+		if version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+			continue
+		}
+
+		mpeg1 := version == 3
+		bitrate := mp3Bitrates[mpeg1][bitrateIndex] * 1000
+		sampleRate := mp3SampleRates[sampleRateIndex]
+		mono := b[i+3]>>6 == 3
+		samplesPerFrame := int64(1152)
+		sideInfo := 32
+		if mono {
+			sideInfo = 17
+		}
+		if !mpeg1 {
+			sampleRate /= 2
+			if version == 0 {
+				// MPEG 2.5
+				sampleRate /= 2
+			}
+			samplesPerFrame = 576
+			sideInfo = 17
+			if mono {
+				sideInfo = 9
+			}
+		}
+
+		frame := b[i:]
+		if xing := 4 + sideInfo; len(frame) >= xing+12 && (string(frame[xing:xing+4]) == "Xing" || string(frame[xing:xing+4]) == "Info") {
+			if binary.BigEndian.Uint32(frame[xing+4:])&0x01 != 0 {
+				frames := int64(binary.BigEndian.Uint32(frame[xing+8:]))
+				return time.Duration(frames*samplesPerFrame) * time.Second / time.Duration(sampleRate), nil
+			}
+		}
+		if vbri := 4 + 32; len(frame) >= vbri+18 && string(frame[vbri:vbri+4]) == "VBRI" {
+			frames := int64(binary.BigEndian.Uint32(frame[vbri+14:]))
+			return time.Duration(frames*samplesPerFrame) * time.Second / time.Duration(sampleRate), nil
+		}
+
+		audioSize := size - start - int64(i)
+		if tail, err := readAt(r, size-128, 3); err == nil && string(tail) == "TAG" {
+			// ID3v1 tag at the end
+			audioSize -= 128
+		}
+		return time.Duration(audioSize*8) * time.Second / time.Duration(bitrate), nil
+	}
+
+	return 0, fmt.Errorf("no mp3 frame found")
+}
+
+// mp4Duration finds the movie header in the moov atom
+func mp4Duration(r io.ReadSeeker, offset int64, end int64) (time.Duration, error) {
+	for offset+8 <= end {
+		header, err := readAt(r, offset, 16)
+		if err != nil {
+			return 0, err
+		}
+		if len(header) < 8 {
+			break
+		}
+
+		size := int64(binary.BigEndian.Uint32(header))
+		name := string(header[4:8])
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			size = end - offset
+		case 1:
+			if len(header) < 16 {
+				return 0, fmt.Errorf("invalid mp4 atom %s", name)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return 0, fmt.Errorf("invalid mp4 atom %s", name)
+		}
+
+		switch name {
+		case "moov":
+			return mp4Duration(r, offset+headerSize, offset+size)
+		case "mvhd":
+			b, err := readAt(r, offset+headerSize, 32)
+			if err != nil {
+				return 0, err
+			}
+			var timescale, duration int64
+			if len(b) >= 32 && b[0] == 1 {
+				timescale = int64(binary.BigEndian.Uint32(b[20:]))
+				duration = int64(binary.BigEndian.Uint64(b[24:]))
+			} else if len(b) >= 20 {
+				timescale = int64(binary.BigEndian.Uint32(b[12:]))
+				duration = int64(binary.BigEndian.Uint32(b[16:]))
+			}
+			if timescale == 0 {
+				return 0, fmt.Errorf("invalid mp4 timescale")
+			}
+			return time.Duration(duration) * time.Second / time.Duration(timescale), nil
+		}
+
+		offset += size
+	}
+
+	return 0, fmt.Errorf("no mp4 movie header found")
+}
+
+// oggDuration divides the granule position of the last page by the sample rate from the first page
+func oggDuration(r io.ReadSeeker, size int64) (time.Duration, error) {
+	first, err := readAt(r, 0, 512)
+	if err != nil {
+		return 0, err
+	}
+	if len(first) < 27 || string(first[:4]) != "OggS" {
+		return 0, fmt.Errorf("missing ogg page")
+	}
+	if len(first) < 27+int(first[26]) {
+		return 0, fmt.Errorf("invalid ogg page")
+	}
+	packet := first[27+int(first[26]):]
+
+	var sampleRate, preSkip int64
+	switch {
+	case len(packet) >= 16 && string(packet[:7]) == "\x01vorbis":
+		sampleRate = int64(binary.LittleEndian.Uint32(packet[12:]))
+	case len(packet) >= 12 && string(packet[:8]) == "OpusHead":
+		// opus granule positions are always at 48kHz
+		sampleRate = 48000
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:]))
+	default:
+		return 0, fmt.Errorf("unsupported ogg codec")
+	}
+	if sampleRate == 0 {
+		return 0, fmt.Errorf("invalid ogg sample rate")
+	}
+
+	tailStart := max(size-64*1024, 0)
+	tail, err := readAt(r, tailStart, int(size-tailStart))
+	if err != nil {
+		return 0, err
+	}
+	// audio data can contain "OggS" so the last page is the last one with a valid checksum
+	for last := bytes.LastIndex(tail, []byte("OggS")); last != -1; last = bytes.LastIndex(tail[:last], []byte("OggS")) {
+		if !validOggPage(tail[last:]) {
+			continue
+		}
+		granule := int64(binary.LittleEndian.Uint64(tail[last+6:]))
+		return time.Duration(granule-preSkip) * time.Second / time.Duration(sampleRate), nil
+	}
+
+	return 0, fmt.Errorf("missing last ogg page")
+}
+
+// validOggPage reports whether b starts with a complete ogg page with a matching checksum
+func validOggPage(b []byte) bool {
+	if len(b) < 27 || b[4] != 0 {
+		return false
+	}
+	headerSize := 27 + int(b[26])
+	if len(b) < headerSize {
+		return false
+	}
+	pageSize := headerSize
+	for _, lacing := range b[27:headerSize] {
+		pageSize += int(lacing)
+	}
+	if len(b) < pageSize {
+		return false
+	}
+
+	// the checksum is calculated with its own field set to zero
+	var crc uint32
+	for i, v := range b[:pageSize] {
+		if i >= 22 && i < 26 {
+			v = 0
+		}
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^v]
+	}
+
+	return crc == binary.LittleEndian.Uint32(b[22:26])
+}
+
+// oggCRCTable is the CRC-32 lookup table of the polynomial 0x04c11db7 without reflection used by ogg
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
@@ -0,0 +1,290 @@
+package library
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+func TestReadDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileType tag.FileType
+		data     []byte
+		want     time.Duration
+	}{
+		{
+			name:     "flac",
+			fileType: tag.FLAC,
+			data:     flacFile(44100, 44100*215),
+			want:     215 * time.Second,
+		},
+		{
+			name:     "flac after id3v2",
+			fileType: tag.FLAC,
+			data:     concat(id3v2Tag(300), flacFile(96000, 96000*61+48000)),
+			want:     61500 * time.Millisecond,
+		},
+		{
+			name:     "mp3 xing",
+			fileType: tag.MP3,
+			// MPEG 1 layer III, 128 kbps, 48 kHz, stereo
+			data: mp3File([]byte{0xFF, 0xFB, 0x94, 0x00}, 32, "Xing", 10000, 4096),
+			// 10000 frames of 1152 samples
+			want: 240 * time.Second,
+		},
+		{
+			name:     "mp3 info after id3v2",
+			fileType: tag.MP3,
+			data:     concat(id3v2Tag(1000), mp3File([]byte{0xFF, 0xFB, 0x94, 0x00}, 32, "Info", 2500, 4096)),
+			want:     60 * time.Second,
+		},
+		{
+			name:     "mpeg 2 mono xing",
+			fileType: tag.MP3,
+			// MPEG 2 layer III, 64 kbps, 24 kHz, mono
+			data: mp3File([]byte{0xFF, 0xF3, 0x84, 0xC0}, 9, "Xing", 5000, 4096),
+			// 5000 frames of 576 samples
+			want: 120 * time.Second,
+		},
+		{
+			name:     "mp3 vbri",
+			fileType: tag.MP3,
+			data:     mp3File([]byte{0xFF, 0xFB, 0x94, 0x00}, 32, "VBRI", 2500, 4096),
+			want:     60 * time.Second,
+		},
+		{
+			name:     "mp3 constant bitrate",
+			fileType: tag.MP3,
+			// MPEG 1 layer III, 128 kbps, 44.1 kHz, 16000 bytes per second
+			data: concat(id3v2Tag(200), mp3File([]byte{0xFF, 0xFB, 0x90, 0x00}, 0, "", 0, 16000*10), id3v1Tag()),
+			want: 10 * time.Second,
+		},
+		{
+			name:     "mp4 mvhd version 0",
+			fileType: tag.M4A,
+			data:     mp4File(mvhd(0, 1000, 215000)),
+			want:     215 * time.Second,
+		},
+		{
+			name:     "mp4 mvhd version 1",
+			fileType: tag.M4A,
+			data:     mp4File(mvhd(1, 44100, 44100*300)),
+			want:     300 * time.Second,
+		},
+		{
+			name:     "ogg vorbis",
+			fileType: tag.OGG,
+			data: concat(
+				oggPage(0x02, 0, vorbisHeader(44100)),
+				oggPage(0, 44100*100, make([]byte, 2000)),
+				oggPage(0x04, 44100*185, make([]byte, 500)),
+			),
+			want: 185 * time.Second,
+		},
+		{
+			name:     "ogg opus",
+			fileType: tag.OGG,
+			data: concat(
+				oggPage(0x02, 0, opusHeader(312)),
+				oggPage(0x04, 48000*10+312, make([]byte, 500)),
+			),
+			want: 10 * time.Second,
+		},
+		{
+			name:     "ogg false sync in last page",
+			fileType: tag.OGG,
+			data: concat(
+				oggPage(0x02, 0, vorbisHeader(48000)),
+				oggPage(0x04, 48000*42, concat(make([]byte, 100), []byte("OggS\x00\x04\xff\xff\xff\xff\xff\xff\x00\x00"), make([]byte, 100))),
+			),
+			want: 42 * time.Second,
+		},
+		{
+			name:     "ogg corrupt last page",
+			fileType: tag.OGG,
+			data: concat(
+				oggPage(0x02, 0, vorbisHeader(48000)),
+				oggPage(0, 48000*30, make([]byte, 100)),
+				corruptOggPage(oggPage(0x04, 48000*42, make([]byte, 100))),
+			),
+			// the previous page is used
+			want: 30 * time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readDuration(bytes.NewReader(test.data), test.fileType)
+			if err != nil {
+				t.Fatalf("error reading duration: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("duration = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadDurationErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileType tag.FileType
+		data     []byte
+	}{
+		{"flac without stream info", tag.FLAC, []byte("fLaC")},
+		{"mp3 without frames", tag.MP3, make([]byte, 1000)},
+		{"mp4 without moov", tag.M4A, mp4Atom("ftyp", []byte("M4A \x00\x00\x00\x00"))},
+		{"ogg with unknown codec", tag.OGG, oggPage(0x02, 0, []byte("\x80theora"))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, err := readDuration(bytes.NewReader(test.data), test.fileType); err == nil {
+				t.Errorf("duration = %s, want an error", got)
+			}
+		})
+	}
+}
+
+func TestOggCRC(t *testing.T) {
+	var crc uint32
+	for _, v := range []byte("123456789") {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^v]
+	}
+	if crc != 0x89A1897F {
+		t.Errorf("crc = %#x, want 0x89a1897f", crc)
+	}
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func id3v2Tag(size int) []byte {
+	header := []byte{'I', 'D', '3', 4, 0, 0, byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}
+	return concat(header, make([]byte, size))
+}
+
+func id3v1Tag() []byte {
+	return concat([]byte("TAG"), make([]byte, 125))
+}
+
+func flacFile(sampleRate int, samples int64) []byte {
+	info := make([]byte, 34)
+	info[10] = byte(sampleRate >> 12)
+	info[11] = byte(sampleRate >> 4)
+	// stereo, 16 bits per sample
+	info[12] = byte(sampleRate&0x0F)<<4 | 1<<1
+	info[13] = 15<<4 | byte(samples>>32&0x0F)
+	binary.BigEndian.PutUint32(info[14:], uint32(samples))
+
+	// last metadata block, STREAMINFO, 34 bytes
+	return concat([]byte("fLaC"), []byte{0x80, 0, 0, 34}, info, make([]byte, 100))
+}
+
+// mp3File returns a frame header followed by a Xing, Info or VBRI header with the frame count after the
+// side information, then size bytes of audio
+func mp3File(frameHeader []byte, sideInfo int, header string, frames uint32, size int) []byte {
+	b := make([]byte, size)
+	copy(b, frameHeader)
+	switch header {
+	case "Xing", "Info":
+		copy(b[4+sideInfo:], header)
+		binary.BigEndian.PutUint32(b[4+sideInfo+4:], 0x01)
+		binary.BigEndian.PutUint32(b[4+sideInfo+8:], frames)
+	case "VBRI":
+		copy(b[4+32:], header)
+		binary.BigEndian.PutUint32(b[4+32+14:], frames)
+	}
+	return b
+}
+
+func mp4Atom(name string, body []byte) []byte {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(8+len(body)))
+	copy(header[4:], name)
+	return concat(header, body)
+}
+
+// mp4File has a free atom with a 64 bit size before the moov atom
+func mp4File(movieHeader []byte) []byte {
+	free := make([]byte, 16)
+	binary.BigEndian.PutUint32(free, 1)
+	copy(free[4:], "free")
+	binary.BigEndian.PutUint64(free[8:], 16+64)
+
+	return concat(
+		mp4Atom("ftyp", []byte("M4A \x00\x00\x00\x00")),
+		free, make([]byte, 64),
+		mp4Atom("moov", mp4Atom("mvhd", movieHeader)),
+		mp4Atom("mdat", make([]byte, 256)),
+	)
+}
+
+func mvhd(version byte, timescale uint32, duration uint64) []byte {
+	b := make([]byte, 100)
+	b[0] = version
+	if version == 1 {
+		binary.BigEndian.PutUint32(b[20:], timescale)
+		binary.BigEndian.PutUint64(b[24:], duration)
+	} else {
+		binary.BigEndian.PutUint32(b[12:], timescale)
+		binary.BigEndian.PutUint32(b[16:], uint32(duration))
+	}
+	return b
+}
+
+func vorbisHeader(sampleRate uint32) []byte {
+	b := make([]byte, 30)
+	copy(b, "\x01vorbis")
+	// channels
+	b[11] = 2
+	binary.LittleEndian.PutUint32(b[12:], sampleRate)
+	return b
+}
+
+func opusHeader(preSkip uint16) []byte {
+	b := make([]byte, 19)
+	copy(b, "OpusHead")
+	b[8] = 1
+	b[9] = 2
+	binary.LittleEndian.PutUint16(b[10:], preSkip)
+	binary.LittleEndian.PutUint32(b[12:], 48000)
+	return b
+}
+
+// oggPage returns a page with the payload as one packet and a valid checksum
+func oggPage(headerType byte, granule uint64, payload []byte) []byte {
+	var lacing []byte
+	for n := len(payload); ; n -= 255 {
+		if n < 255 {
+			lacing = append(lacing, byte(n))
+			break
+		}
+		lacing = append(lacing, 255)
+	}
+
+	header := make([]byte, 27)
+	copy(header, "OggS")
+	header[5] = headerType
+	binary.LittleEndian.PutUint64(header[6:], granule)
+	binary.LittleEndian.PutUint32(header[14:], 1)
+	header[26] = byte(len(lacing))
+
+	page := concat(header, lacing, payload)
+	var crc uint32
+	for _, v := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^v]
+	}
+	binary.LittleEndian.PutUint32(page[22:], crc)
+	return page
+}
+
+func corruptOggPage(page []byte) []byte {
+	page[len(page)-1] ^= 0xFF
+	return page
+}
@@ -0,0 +1,136 @@
+// Package library indexes the tags of a local folder of music files so playlists can be matched
+// against it without a server.
+package library
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zibbp/music-utils/utils"
+)
+
+// Extensions of the files which are indexed
+var Extensions = []string{".flac", ".mp3", ".m4a", ".mp4", ".alac", ".aac", ".ogg", ".oga", ".opus"}
+
+// File is an indexed music file. Path is relative to the library root and always uses forward slashes.
+type File struct {
+	Path        string    `json:"path"`
+	ISRC        string    `json:"isrc"`
+	Title       string    `json:"title"`
+	Artists     []string  `json:"artists"`
+	Album       string    `json:"album"`
+	AlbumArtist string    `json:"album_artist"`
+	TrackNumber int       `json:"track_number"`
+	DiscNumber  int       `json:"disc_number"`
+	Year        int       `json:"year"`
+	Duration    int64     `json:"duration"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+}
+
+type Index struct {
+	Root      string    `json:"root"`
+	IndexedAt time.Time `json:"indexed_at"`
+	Files     []File    `json:"files"`
+	path      string
+}
+
+// Load reads the index at path. A missing file is an empty index.
+func Load(path string) (*Index, error) {
+	index := &Index{path: path}
+
+	err := utils.ReadJsonFromFile(filepath.Dir(path), strings.TrimSuffix(filepath.Base(path), ".json"), index)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// Save writes the index back to the file
+func (i *Index) Save() error {
+	return utils.WriteJsonToFile(filepath.Dir(i.path), strings.TrimSuffix(filepath.Base(i.path), ".json"), i)
+}
+
+// Scan reads the tags of every music file under root into the index, replacing what was indexed
+// before. Files unchanged since the last scan of the same root are not read again. Files which
+// can't be read are logged and skipped. It returns the number of files read.
+func (i *Index) Scan(root string) (int, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return 0, err
+	}
+
+	previous := make(map[string]File)
+	if i.Root == root {
+		for _, file := range i.Files {
+			previous[file.Path] = file
+		}
+	}
+
+	var files []File
+	read := 0
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !isMusicFile(path) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if file, ok := previous[rel]; ok && file.Size == info.Size() && file.ModTime.Equal(info.ModTime()) {
+			files = append(files, file)
+			return nil
+		}
+
+		file, err := readFile(path)
+		if err != nil {
+			log.Warn().Err(err).Str("file", rel).Msg("error reading tags, skipping file")
+			return nil
+		}
+		file.Path = rel
+		file.Size = info.Size()
+		file.ModTime = info.ModTime()
+		files = append(files, file)
+
+		read++
+		if read%500 == 0 {
+			log.Info().Int("files", read).Msg("reading tags")
+		}
+		return nil
+	})
+	if err != nil {
+		return read, err
+	}
+
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].Path < files[b].Path
+	})
+
+	i.Root = root
+	i.IndexedAt = time.Now().UTC()
+	i.Files = files
+
+	return read, nil
+}
+
+func isMusicFile(path string) bool {
+	return slices.Contains(Extensions, strings.ToLower(filepath.Ext(path)))
+}
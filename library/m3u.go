@@ -0,0 +1,71 @@
+package library
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/zibbp/music-utils/matcher"
+)
+
+// WriteM3U8 writes the matched tracks as an extended M3U8 playlist. File paths are relative to
+// libraryRoot, or the index root when it is empty. Tracks without a file are written as comments
+// with their ISRC so the playlist can be exported again once they are in the library.
+func (i *Index) WriteM3U8(w io.Writer, name string, matches []TrackMatch, libraryRoot string) error {
+	if libraryRoot == "" {
+		libraryRoot = i.Root
+	}
+	libraryRoot, err := filepath.Abs(libraryRoot)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	fmt.Fprintf(bw, "#PLAYLIST:%s\n", oneLine(name))
+
+	for _, match := range matches {
+		if match.File == nil {
+			isrc := match.Track.ISRC
+			if isrc == "" {
+				isrc = "unknown"
+			}
+			fmt.Fprintf(bw, "# not found, ISRC %s: %s\n", isrc, extinf(match.Track, match.Track.Duration.Seconds()))
+			continue
+		}
+
+		path, err := filepath.Rel(libraryRoot, filepath.Join(i.Root, filepath.FromSlash(match.File.Path)))
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(bw, extinf(match.Track, float64(match.File.Duration)))
+		fmt.Fprintln(bw, filepath.ToSlash(path))
+	}
+
+	return bw.Flush()
+}
+
+// extinf returns the #EXTINF line of the track, the duration is -1 when unknown
+func extinf(track matcher.Track, seconds float64) string {
+	duration := int64(seconds)
+	if duration <= 0 {
+		duration = -1
+	}
+
+	title := track.Title
+	if track.Version != "" && !strings.Contains(strings.ToLower(title), strings.ToLower(track.Version)) {
+		title = fmt.Sprintf("%s (%s)", title, track.Version)
+	}
+	if len(track.Artists) > 0 {
+		title = strings.Join(track.Artists, ", ") + " - " + title
+	}
+
+	return fmt.Sprintf("#EXTINF:%d,%s", duration, oneLine(title))
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
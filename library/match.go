@@ -0,0 +1,150 @@
+package library
+
+import (
+	"strings"
+	"time"
+
+	"github.com/zibbp/music-utils/matcher"
+	"github.com/zibbp/music-utils/navidrome"
+)
+
+// TrackMatch is the file a playlist track resolved to. File is nil when no file matched.
+type TrackMatch struct {
+	Track      matcher.Track
+	File       *File
+	Confidence float64
+	Method     matcher.Method
+}
+
+// Matcher resolves tracks to indexed files. Only files sharing the ISRC, title or primary artist
+// of a track are scored.
+type Matcher struct {
+	index    *Index
+	matcher  *matcher.Matcher
+	byISRC   map[string][]int
+	byTitle  map[string][]int
+	byArtist map[string][]int
+}
+
+func NewMatcher(index *Index, m *matcher.Matcher) *Matcher {
+	lm := &Matcher{
+		index:    index,
+		matcher:  m,
+		byISRC:   make(map[string][]int),
+		byTitle:  make(map[string][]int),
+		byArtist: make(map[string][]int),
+	}
+
+	for i, file := range index.Files {
+		if file.ISRC != "" {
+			lm.byISRC[file.ISRC] = append(lm.byISRC[file.ISRC], i)
+		}
+		if title := matcher.NormalizeTitle(file.Title); title != "" {
+			lm.byTitle[title] = append(lm.byTitle[title], i)
+		}
+		if len(file.Artists) > 0 {
+			artist := matcher.Normalize(file.Artists[0])
+			lm.byArtist[artist] = append(lm.byArtist[artist], i)
+		}
+	}
+
+	return lm
+}
+
+// Match returns the file which best matches the track
+func (m *Matcher) Match(track matcher.Track) TrackMatch {
+	seen := make(map[int]bool)
+	var indexes []int
+	add := func(candidates []int) {
+		for _, i := range candidates {
+			if !seen[i] {
+				seen[i] = true
+				indexes = append(indexes, i)
+			}
+		}
+	}
+	if track.ISRC != "" {
+		add(m.byISRC[strings.ToUpper(strings.ReplaceAll(track.ISRC, "-", ""))])
+	}
+	add(m.byTitle[matcher.NormalizeTitle(track.Title)])
+	for _, artist := range track.Artists {
+		add(m.byArtist[matcher.Normalize(artist)])
+	}
+
+	candidates := make([]matcher.Track, 0, len(indexes))
+	for _, i := range indexes {
+		candidates = append(candidates, m.index.Files[i].ToMatcherTrack())
+	}
+
+	result := TrackMatch{Track: track}
+	best := m.matcher.Best(track, candidates)
+	if best == nil {
+		return result
+	}
+
+	// the same recording is often in the library several times, e.g. on an album and a compilation
+	chosen := best.Index
+	for i, candidate := range candidates {
+		if i == best.Index {
+			continue
+		}
+		if confidence, _ := m.matcher.Score(track, candidate); confidence == best.Confidence && editionScore(track, candidate) > editionScore(track, candidates[chosen]) {
+			chosen = i
+		}
+	}
+
+	result.File = &m.index.Files[indexes[chosen]]
+	result.Confidence = best.Confidence
+	result.Method = best.Method
+
+	return result
+}
+
+// editionScore counts the edition details the file shares with the track
+func editionScore(track, file matcher.Track) int {
+	score := 0
+	if track.AlbumArtist != "" && matcher.Normalize(track.AlbumArtist) == matcher.Normalize(file.AlbumArtist) {
+		score++
+	}
+	if track.TrackNumber != 0 && track.TrackNumber == file.TrackNumber && (track.DiscNumber == file.DiscNumber || track.DiscNumber == 0 || file.DiscNumber == 0) {
+		score++
+	}
+	if track.Year != 0 && track.Year == file.Year {
+		score++
+	}
+	return score
+}
+
+// MatchPlaylist matches every track of the playlist, keeping the playlist order
+func (m *Matcher) MatchPlaylist(playlist navidrome.Playlist) []TrackMatch {
+	tracks := make([]matcher.Track, 0, len(playlist.Tracks))
+	for _, track := range playlist.Tracks {
+		tracks = append(tracks, track.ToMatcherTrack())
+	}
+	return m.MatchTracks(tracks)
+}
+
+// MatchTracks matches every track, keeping their order
+func (m *Matcher) MatchTracks(tracks []matcher.Track) []TrackMatch {
+	matches := make([]TrackMatch, 0, len(tracks))
+	for _, track := range tracks {
+		matches = append(matches, m.Match(track))
+	}
+	return matches
+}
+
+// ToMatcherTrack converts the file for use with the matcher
+func (f File) ToMatcherTrack() matcher.Track {
+	return matcher.Track{
+		ID:          f.Path,
+		ISRC:        f.ISRC,
+		Title:       f.Title,
+		Artists:     f.Artists,
+		Album:       f.Album,
+		Duration:    time.Duration(f.Duration) * time.Second,
+		AlbumArtist: f.AlbumArtist,
+		TrackNumber: f.TrackNumber,
+		DiscNumber:  f.DiscNumber,
+		Year:        f.Year,
+	}
+}
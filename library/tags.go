@@ -0,0 +1,109 @@
+package library
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+	"github.com/rs/zerolog/log"
+)
+
+// Raw tag names holding the ISRC and the list of all artists in ID3, Vorbis and MP4 tags
+var (
+	isrcTags    = []string{"isrc", "tsrc", "trc"}
+	artistsTags = []string{"artists"}
+)
+
+// readFile reads the tags and duration of a music file
+func readFile(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	metadata, err := tag.ReadFrom(f)
+	if err != nil {
+		return File{}, fmt.Errorf("error reading tags: %v", err)
+	}
+
+	trackNumber, _ := metadata.Track()
+	discNumber, _ := metadata.Disc()
+	file := File{
+		Title:       strings.TrimSpace(metadata.Title()),
+		Album:       strings.TrimSpace(metadata.Album()),
+		AlbumArtist: strings.TrimSpace(metadata.AlbumArtist()),
+		TrackNumber: trackNumber,
+		DiscNumber:  discNumber,
+		Year:        metadata.Year(),
+	}
+
+	raw := metadata.Raw()
+	if isrc := rawValues(raw, isrcTags); len(isrc) > 0 {
+		file.ISRC = strings.ToUpper(strings.ReplaceAll(isrc[0], "-", ""))
+	}
+
+	// the artist tag is the primary artist, an artists tag written by taggers such as Picard lists everyone
+	if artist := strings.TrimSpace(metadata.Artist()); artist != "" {
+		file.Artists = append(file.Artists, artist)
+	}
+	for _, artist := range rawValues(raw, artistsTags) {
+		if len(file.Artists) > 0 && strings.EqualFold(file.Artists[0], artist) {
+			continue
+		}
+		file.Artists = append(file.Artists, artist)
+	}
+
+	// matching works without the duration so a file with unusual headers is still indexed
+	duration, err := readDuration(f, metadata.FileType())
+	if err != nil {
+		log.Debug().Err(err).Str("file", path).Msg("error reading duration")
+	}
+	file.Duration = int64(duration.Round(time.Second).Seconds())
+
+	return file, nil
+}
+
+// rawValues returns the values of the raw tags with any of the names, ignoring case. Values holding
+// several entries separated by semicolons or null characters are split.
+func rawValues(raw map[string]interface{}, names []string) []string {
+	var values []string
+	for key, value := range raw {
+		var text string
+		switch v := value.(type) {
+		case string:
+			if !hasName(key, names) {
+				continue
+			}
+			text = v
+		case *tag.Comm:
+			// ID3 user defined text frames are named by their description
+			if !hasName(v.Description, names) {
+				continue
+			}
+			text = v.Text
+		default:
+			continue
+		}
+
+		for _, entry := range strings.FieldsFunc(text, func(r rune) bool { return r == ';' || r == 0 }) {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				values = append(values, entry)
+			}
+		}
+	}
+	return values
+}
+
+func hasName(key string, names []string) bool {
+	// repeated ID3 frames are suffixed with an index, e.g. TXXX_0
+	key, _, _ = strings.Cut(key, "_")
+	for _, name := range names {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
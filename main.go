@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/rs/zerolog"
@@ -8,6 +9,7 @@ import (
 	"github.com/urfave/cli/v2"
 	"github.com/zibbp/music-utils/commands"
 	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/navidrome"
 )
 
 func initialize() (*config.Config, *config.JsonConfigService) {
@@ -31,6 +33,34 @@ func initialize() (*config.Config, *config.JsonConfigService) {
 	return c, jsonConfig
 }
 
+// planFlags are shared by commands which change playlists
+func planFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Print the planned changes without changing anything",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Format of the dry run plan (table, json). A JSON plan can be applied with --apply-plan",
+			Value: "table",
+		},
+		&cli.StringFlag{
+			Name:  "apply-plan",
+			Usage: "Apply a JSON plan saved from a dry run instead of planning again",
+		},
+	}
+}
+
+// importNavidromePlaylists imports the Navidrome format playlists at path, or applies the plan given with --apply-plan
+func importNavidromePlaylists(cCtx *cli.Context, c *config.Config, jsonConfig *config.JsonConfigService, path string) error {
+	if planPath := cCtx.String("apply-plan"); planPath != "" {
+		return commands.ApplyPlan(cCtx.Context, c, jsonConfig, "navidrome import", planPath)
+	}
+
+	return commands.ImportNavidromePlaylists(cCtx.Context, c, path, cCtx.Bool("dry-run"), cCtx.String("format"))
+}
+
 func main() {
 	app := &cli.App{
 		Name:  "music-utils",
@@ -43,20 +73,81 @@ func main() {
 					{
 						Name:  "save",
 						Usage: "Save all user tidal playlists to a JSON file",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.BoolFlag{
 								Name:  "save-navidrome-format",
 								Usage: "Save a version of the tidal playlist in a format for importing into Navidrome",
 							},
+							&cli.BoolFlag{
+								Name:  "import-to-navidrome",
+								Usage: "Save the Navidrome format and create or update the playlists on the Navidrome server. The plan flags apply to the import",
+							},
+							&cli.BoolFlag{
+								Name:  "full",
+								Usage: "Save all playlists, including those unchanged since the last save",
+							},
+						}, planFlags()...),
+						Action: func(cCtx *cli.Context) error {
+							importToNavidrome := cCtx.Bool("import-to-navidrome")
+							saveNavidromeFormat := cCtx.Bool("save-navidrome-format") || importToNavidrome
+							if !importToNavidrome && (cCtx.Bool("dry-run") || cCtx.String("apply-plan") != "") {
+								return fmt.Errorf("--dry-run and --apply-plan require --import-to-navidrome")
+							}
+
+							c, jsonConfig := initialize()
+							full := cCtx.Bool("full")
+
+							err := commands.SaveTidalPlaylists(cCtx.Context, c, jsonConfig, saveNavidromeFormat, full)
+							if err != nil {
+								log.Fatal().Err(err).Msg("error saving tidal playlists")
+							}
+
+							if importToNavidrome {
+								if err := importNavidromePlaylists(cCtx, c, jsonConfig, navidrome.DefaultPath); err != nil {
+									log.Fatal().Err(err).Msg("error importing navidrome playlists")
+								}
+							}
+
+							return nil
 						},
+					},
+					{
+						Name:  "save-favorites",
+						Usage: "Save all user tidal favorite tracks, albums, artists and mixes to JSON files",
 						Action: func(cCtx *cli.Context) error {
 							c, jsonConfig := initialize()
 
-							saveNavidromeFormat := cCtx.Bool("save-navidrome-format")
+							err := commands.SaveTidalFavorites(cCtx.Context, c, jsonConfig)
+							if err != nil {
+								log.Fatal().Err(err).Msg("error saving tidal favorites")
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:      "restore",
+						Usage:     "Restore tidal playlists from a saved playlist file or directory",
+						ArgsUsage: "[path]",
+						Flags:     planFlags(),
+						Action: func(cCtx *cli.Context) error {
+							path := cCtx.Args().First()
+							if path == "" {
+								path = "/data/tidal"
+							}
+
+							c, jsonConfig := initialize()
 
-							err := commands.SaveTidalPlaylists(cCtx.Context, c, jsonConfig, saveNavidromeFormat)
+							if planPath := cCtx.String("apply-plan"); planPath != "" {
+								if err := commands.ApplyPlan(cCtx.Context, c, jsonConfig, "tidal restore", planPath); err != nil {
+									log.Fatal().Err(err).Msg("error applying plan")
+								}
+								return nil
+							}
+
+							err := commands.RestoreTidalPlaylists(cCtx.Context, c, jsonConfig, path, cCtx.Bool("dry-run"), cCtx.String("format"))
 							if err != nil {
-								log.Fatal().Err(err).Msg("error saving tidal playlists")
+								log.Fatal().Err(err).Msg("error restoring tidal playlists")
 							}
 
 							return nil
@@ -87,6 +178,48 @@ func main() {
 								log.Fatal().Err(err).Msg("error printing tidal playlists")
 							}
 
+							return nil
+						},
+					},
+					{
+						Name:      "search",
+						Usage:     "Search the Tidal catalog",
+						ArgsUsage: "<query>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "type",
+								Usage: "Type of item to search for (tracks, albums, artists, playlists)",
+								Value: "tracks",
+							},
+							&cli.StringFlag{
+								Name:  "isrc",
+								Usage: "Lookup tracks by ISRC instead of searching",
+							},
+							&cli.IntFlag{
+								Name:  "limit",
+								Usage: "Number of results to return",
+								Value: 10,
+							},
+							&cli.IntFlag{
+								Name:  "offset",
+								Usage: "Offset of the first result to return",
+								Value: 0,
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							query := cCtx.Args().First()
+							isrc := cCtx.String("isrc")
+							if query == "" && isrc == "" {
+								return fmt.Errorf("a search query or --isrc is required")
+							}
+
+							c, jsonConfig := initialize()
+
+							err := commands.SearchTidal(cCtx.Context, c, jsonConfig, query, cCtx.String("type"), isrc, cCtx.Int("limit"), cCtx.Int("offset"))
+							if err != nil {
+								log.Fatal().Err(err).Msg("error searching tidal")
+							}
+
 							return nil
 						},
 					},
@@ -98,15 +231,72 @@ func main() {
 				Subcommands: []*cli.Command{
 					{
 						Name:  "save",
-						Usage: "Save all user spotify playlists to a JSON file",
+						Usage: "Save all user spotify playlists and library to JSON files",
+						Flags: append([]cli.Flag{
+							&cli.BoolFlag{
+								Name:  "save-navidrome-format",
+								Usage: "Save a version of the spotify playlist in a format for importing into Navidrome",
+							},
+							&cli.BoolFlag{
+								Name:  "import-to-navidrome",
+								Usage: "Save the Navidrome format and create or update the playlists on the Navidrome server. The plan flags apply to the import",
+							},
+							&cli.BoolFlag{
+								Name:  "full",
+								Usage: "Save all playlists, including those unchanged since the last save",
+							},
+						}, planFlags()...),
 						Action: func(cCtx *cli.Context) error {
+							importToNavidrome := cCtx.Bool("import-to-navidrome")
+							saveNavidromeFormat := cCtx.Bool("save-navidrome-format") || importToNavidrome
+							if !importToNavidrome && (cCtx.Bool("dry-run") || cCtx.String("apply-plan") != "") {
+								return fmt.Errorf("--dry-run and --apply-plan require --import-to-navidrome")
+							}
+
 							c, jsonConfig := initialize()
+							full := cCtx.Bool("full")
 
-							err := commands.SaveSpotifyPlaylists(cCtx.Context, c, jsonConfig)
+							err := commands.SaveSpotifyPlaylists(cCtx.Context, c, jsonConfig, saveNavidromeFormat, full)
 							if err != nil {
 								log.Fatal().Err(err).Msg("error saving spotify playlists")
 							}
 
+							if importToNavidrome {
+								if err := importNavidromePlaylists(cCtx, c, jsonConfig, navidrome.DefaultPath); err != nil {
+									log.Fatal().Err(err).Msg("error importing navidrome playlists")
+								}
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:      "restore",
+						Usage:     "Restore spotify playlists from a saved playlist file or directory",
+						ArgsUsage: "[path]",
+						Flags:     planFlags(),
+						Action: func(cCtx *cli.Context) error {
+							path := cCtx.Args().First()
+							if path == "" {
+								path = "/data/spotify"
+							}
+
+							c, jsonConfig := initialize()
+
+							if planPath := cCtx.String("apply-plan"); planPath != "" {
+								if err := commands.ApplyPlan(cCtx.Context, c, jsonConfig, "spotify restore", planPath); err != nil {
+									log.Fatal().Err(err).Msg("error applying plan")
+								}
+								return nil
+							}
+
+							dryRun := cCtx.Bool("dry-run")
+
+							err := commands.RestoreSpotifyPlaylists(cCtx.Context, c, jsonConfig, path, dryRun, cCtx.String("format"))
+							if err != nil {
+								log.Fatal().Err(err).Msg("error restoring spotify playlists")
+							}
+
 							return nil
 						},
 					},
@@ -126,11 +316,26 @@ func main() {
 					},
 					{
 						Name:  "create-tidal-playlists",
-						Usage: "Create Spotify playlists on Tidal. Used in conjunction with https://github.com/spotify2tidal/spotify_to_tidal",
+						Usage: "Create Spotify playlists on Tidal and transfer their tracks",
+						Flags: append([]cli.Flag{
+							&cli.BoolFlag{
+								Name:  "mirror",
+								Usage: "Remove Tidal tracks not in the Spotify playlist and match the Spotify order",
+							},
+						}, planFlags()...),
 						Action: func(cCtx *cli.Context) error {
 							c, jsonConfig := initialize()
 
-							err := commands.CreateSpotifyPlaylistsOnTidal(cCtx.Context, c, jsonConfig)
+							if planPath := cCtx.String("apply-plan"); planPath != "" {
+								if err := commands.ApplyPlan(cCtx.Context, c, jsonConfig, "create-tidal-playlists", planPath); err != nil {
+									log.Fatal().Err(err).Msg("error applying plan")
+								}
+								return nil
+							}
+
+							mirror := cCtx.Bool("mirror")
+
+							err := commands.CreateSpotifyPlaylistsOnTidal(cCtx.Context, c, jsonConfig, mirror, cCtx.Bool("dry-run"), cCtx.String("format"))
 							if err != nil {
 								log.Fatal().Err(err).Msg("error printing spotify playlists")
 							}
@@ -140,10 +345,307 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:  "sync",
+				Usage: "Sync playlists from one provider to another (spotify, tidal, navidrome, navidrome-file)",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "from",
+						Usage: "Provider to sync playlists from",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "Provider to sync playlists to",
+					},
+					&cli.StringSliceFlag{
+						Name:  "playlist",
+						Usage: "ID or name of a playlist to sync, can be repeated. Defaults to all playlists",
+					},
+					&cli.BoolFlag{
+						Name:  "mirror",
+						Usage: "Remove tracks from the destination playlist which are not in the source playlist and match the source order",
+					},
+				}, planFlags()...),
+				Action: func(cCtx *cli.Context) error {
+					planPath := cCtx.String("apply-plan")
+					if planPath == "" && (cCtx.String("from") == "" || cCtx.String("to") == "") {
+						return fmt.Errorf("--from and --to are required")
+					}
+
+					c, jsonConfig := initialize()
+
+					if planPath != "" {
+						if err := commands.ApplyPlan(cCtx.Context, c, jsonConfig, "sync", planPath); err != nil {
+							log.Fatal().Err(err).Msg("error applying plan")
+						}
+						return nil
+					}
+
+					err := commands.Sync(cCtx.Context, c, jsonConfig, cCtx.String("from"), cCtx.String("to"), cCtx.StringSlice("playlist"), cCtx.Bool("mirror"), cCtx.Bool("dry-run"), cCtx.String("format"))
+					if err != nil {
+						log.Fatal().Err(err).Msg("error syncing playlists")
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "navidrome",
+				Usage: "Navidrome related commands",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "import",
+						Usage:     "Create or update Navidrome playlists from Navidrome format playlist files, matching tracks against the library",
+						ArgsUsage: "[path]",
+						Flags:     planFlags(),
+						Action: func(cCtx *cli.Context) error {
+							path := cCtx.Args().First()
+							if path == "" {
+								path = navidrome.DefaultPath
+							}
+
+							c, jsonConfig := initialize()
+
+							if err := importNavidromePlaylists(cCtx, c, jsonConfig, path); err != nil {
+								log.Fatal().Err(err).Msg("error importing navidrome playlists")
+							}
+
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "local",
+				Usage: "Match playlists against a local folder of music files",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "index",
+						Usage:     "Read the tags of every music file in a directory into the library index",
+						ArgsUsage: "<dir>",
+						Action: func(cCtx *cli.Context) error {
+							dir := cCtx.Args().First()
+							if dir == "" {
+								return fmt.Errorf("a music directory is required")
+							}
+
+							c, _ := initialize()
+
+							err := commands.IndexLocalLibrary(cCtx.Context, c, dir)
+							if err != nil {
+								log.Fatal().Err(err).Msg("error indexing local library")
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:      "match",
+						Usage:     "Match the tracks of Navidrome format playlists against the library index",
+						ArgsUsage: "[path]",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "unmatched",
+								Usage: "Only print tracks without a matching file",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							path := cCtx.Args().First()
+							if path == "" {
+								path = navidrome.DefaultPath
+							}
+
+							c, _ := initialize()
+
+							err := commands.MatchLocalPlaylists(cCtx.Context, c, path, cCtx.Bool("unmatched"))
+							if err != nil {
+								log.Fatal().Err(err).Msg("error matching playlists against local library")
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:      "export",
+						Usage:     "Export saved playlists as M3U8 files of the matching local files",
+						ArgsUsage: "[path]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "from",
+								Usage: "Format of the saved playlists (navidrome, tidal, spotify)",
+								Value: "navidrome",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "Directory the M3U8 files are written to",
+								Value: "/data/m3u",
+							},
+							&cli.StringFlag{
+								Name:  "library-root",
+								Usage: "Directory the paths in the M3U8 files are relative to, defaults to the indexed directory",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							from := cCtx.String("from")
+							path := cCtx.Args().First()
+							if path == "" {
+								switch from {
+								case "tidal":
+									path = "/data/tidal"
+								case "spotify":
+									path = "/data/spotify"
+								default:
+									path = navidrome.DefaultPath
+								}
+							}
+
+							c, _ := initialize()
+
+							err := commands.ExportLocalPlaylists(cCtx.Context, c, from, path, cCtx.String("output"), cCtx.String("library-root"))
+							if err != nil {
+								log.Fatal().Err(err).Msg("error exporting m3u8 playlists")
+							}
+
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "mapping",
+				Usage: "Manage which playlists are synced to each other",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "Print all playlist mappings",
+						Action: func(cCtx *cli.Context) error {
+							c, _ := initialize()
+
+							err := commands.PrintPlaylistMappings(cCtx.Context, c)
+							if err != nil {
+								log.Fatal().Err(err).Msg("error printing playlist mappings")
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:      "set",
+						Usage:     "Map a source playlist to a destination playlist",
+						ArgsUsage: "<source playlist id> <destination playlist id>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "from",
+								Usage: "Provider of the source playlist",
+								Value: "spotify",
+							},
+							&cli.StringFlag{
+								Name:  "to",
+								Usage: "Provider of the destination playlist",
+								Value: "tidal",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.NArg() != 2 {
+								return fmt.Errorf("a source and destination playlist id are required")
+							}
+
+							c, _ := initialize()
+
+							err := commands.SetPlaylistMapping(cCtx.Context, c, cCtx.String("from"), cCtx.String("to"), cCtx.Args().Get(0), cCtx.Args().Get(1))
+							if err != nil {
+								log.Fatal().Err(err).Msg("error setting playlist mapping")
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:      "unset",
+						Usage:     "Remove the mapping of a source playlist",
+						ArgsUsage: "<source playlist id>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "from",
+								Usage: "Provider of the source playlist",
+								Value: "spotify",
+							},
+							&cli.StringFlag{
+								Name:  "to",
+								Usage: "Provider of the destination playlist",
+								Value: "tidal",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							sourceId := cCtx.Args().First()
+							if sourceId == "" {
+								return fmt.Errorf("a source playlist id is required")
+							}
+
+							c, _ := initialize()
+
+							err := commands.UnsetPlaylistMapping(cCtx.Context, c, cCtx.String("from"), cCtx.String("to"), sourceId)
+							if err != nil {
+								log.Fatal().Err(err).Msg("error removing playlist mapping")
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "import",
+						Usage: "Import Spotify to Tidal mappings from the Spotify IDs in Tidal playlist descriptions",
+						Action: func(cCtx *cli.Context) error {
+							c, jsonConfig := initialize()
+
+							err := commands.ImportPlaylistMappings(cCtx.Context, c, jsonConfig)
+							if err != nil {
+								log.Fatal().Err(err).Msg("error importing playlist mappings")
+							}
+
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:      "history",
+				Usage:     "Show the saved snapshots of a playlist and the tracks added and removed between them",
+				ArgsUsage: "<playlist id or name>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "from",
+						Usage: "Snapshot to compare from, defaults to the second latest",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "Snapshot to compare to, defaults to the latest",
+					},
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "Show the changes between every snapshot",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					playlist := cCtx.Args().First()
+					if playlist == "" {
+						return fmt.Errorf("a playlist id or name is required")
+					}
+
+					c, _ := initialize()
+
+					err := commands.PrintPlaylistHistory(cCtx.Context, c, playlist, cCtx.String("from"), cCtx.String("to"), cCtx.Bool("all"))
+					if err != nil {
+						log.Fatal().Err(err).Msg("error printing playlist history")
+					}
+
+					return nil
+				},
+			},
 		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
-		log.Fatal().Err(err)
+		log.Fatal().Err(err).Msg("error running command")
 	}
 }
@@ -0,0 +1,217 @@
+// Package matcher matches tracks between providers using ISRC and normalized metadata.
+package matcher
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+type Method string
+
+const (
+	MethodISRC     Method = "isrc"
+	MethodMetadata Method = "metadata"
+)
+
+// Track is the provider neutral view of a track used for matching
+type Track struct {
+	ID       string
+	ISRC     string
+	Title    string
+	Version  string
+	Artists  []string
+	Album    string
+	Duration time.Duration
+	Explicit bool
+	// Edition details, they are not scored but tell apart equally good matches such as
+	// the same recording on an album and a compilation
+	AlbumArtist string
+	TrackNumber int
+	DiscNumber  int
+	Year        int
+}
+
+type Match struct {
+	// Index of the matched candidate
+	Index      int
+	Track      Track
+	Confidence float64
+	Method     Method
+}
+
+type Options struct {
+	// Maximum duration difference for a full duration score
+	DurationTolerance time.Duration
+	// Matches below this confidence are discarded
+	MinConfidence float64
+	// Metadata matches whose artists are less similar than this are discarded, otherwise a cover
+	// with the same title, album and duration reaches MinConfidence
+	MinArtistSimilarity float64
+}
+
+type Matcher struct {
+	options Options
+}
+
+var DefaultOptions = Options{
+	DurationTolerance:   3 * time.Second,
+	MinConfidence:       0.7,
+	MinArtistSimilarity: 0.5,
+}
+
+func New(options Options) *Matcher {
+	return &Matcher{options: options}
+}
+
+// Default returns a matcher using DefaultOptions
+func Default() *Matcher {
+	return New(DefaultOptions)
+}
+
+// Best returns the candidate that best matches the source track or nil if no
+// candidate reaches the minimum confidence.
+func (m *Matcher) Best(source Track, candidates []Track) *Match {
+	var best *Match
+	for i, candidate := range candidates {
+		confidence, method := m.Score(source, candidate)
+		if confidence < m.options.MinConfidence {
+			continue
+		}
+		if best == nil || confidence > best.Confidence || (confidence == best.Confidence && method == MethodISRC && best.Method != MethodISRC) {
+			best = &Match{
+				Index:      i,
+				Track:      candidate,
+				Confidence: confidence,
+				Method:     method,
+			}
+		}
+	}
+
+	return best
+}
+
+// Score returns the confidence, between 0 and 1, that both tracks are the same recording
+// and the method which produced it.
+func (m *Matcher) Score(a, b Track) (float64, Method) {
+	if a.ISRC != "" && strings.EqualFold(a.ISRC, b.ISRC) {
+		// the same ISRC is the same recording, only penalize a clean/explicit mismatch
+		if a.Explicit != b.Explicit {
+			return 0.95, MethodISRC
+		}
+		return 1, MethodISRC
+	}
+
+	return m.metadataScore(a, b), MethodMetadata
+}
+
+func (m *Matcher) metadataScore(a, b Track) float64 {
+	aTitle, aVersion := SplitVersion(a.Title)
+	bTitle, bVersion := SplitVersion(b.Title)
+	if a.Version != "" {
+		aVersion = strings.TrimSpace(aVersion + " " + a.Version)
+	}
+	if b.Version != "" {
+		bVersion = strings.TrimSpace(bVersion + " " + b.Version)
+	}
+
+	titleScore := tokenSimilarity(Normalize(aTitle), Normalize(bTitle))
+	artistScore := artistSimilarity(a.Artists, b.Artists)
+	// tracks without artists can't be checked
+	if len(a.Artists) > 0 && len(b.Artists) > 0 && artistScore < m.options.MinArtistSimilarity {
+		return 0
+	}
+	albumScore := albumSimilarity(a.Album, b.Album)
+	durationScore := m.durationSimilarity(a.Duration, b.Duration)
+
+	score := 0.45*titleScore + 0.3*artistScore + 0.1*albumScore + 0.15*durationScore
+
+	// a live or remixed recording is a different recording
+	aTags := versionTags(aVersion)
+	bTags := versionTags(bVersion)
+	for _, tag := range []string{"live", "acoustic", "remix", "instrumental", "demo"} {
+		if aTags[tag] != bTags[tag] {
+			score -= 0.2
+		}
+	}
+	for _, tag := range []string{"edit", "remaster", "mono"} {
+		if aTags[tag] != bTags[tag] {
+			score -= 0.05
+		}
+	}
+
+	if a.Explicit != b.Explicit {
+		score -= 0.05
+	}
+
+	return math.Max(0, math.Min(1, score))
+}
+
+func artistSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	// the primary artists matching is a full score, featured artists are often listed differently
+	if Normalize(a[0]) == Normalize(b[0]) {
+		return 1
+	}
+
+	aSet := make(map[string]bool, len(a))
+	for _, artist := range a {
+		aSet[Normalize(artist)] = true
+	}
+
+	best := 0.0
+	for _, artist := range b {
+		normalized := Normalize(artist)
+		if aSet[normalized] {
+			return 0.9
+		}
+		for aArtist := range aSet {
+			best = math.Max(best, tokenSimilarity(aArtist, normalized))
+		}
+	}
+
+	return best
+}
+
+func albumSimilarity(a, b string) float64 {
+	if a == "" || b == "" {
+		// unknown, don't reward or punish
+		return 0.5
+	}
+
+	aAlbum := NormalizeAlbum(a)
+	bAlbum := NormalizeAlbum(b)
+	if aAlbum == bAlbum {
+		return 1
+	}
+	if strings.Contains(aAlbum, bAlbum) || strings.Contains(bAlbum, aAlbum) {
+		return 0.7
+	}
+
+	return tokenSimilarity(aAlbum, bAlbum)
+}
+
+func (m *Matcher) durationSimilarity(a, b time.Duration) float64 {
+	if a == 0 || b == 0 {
+		return 0.5
+	}
+
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+
+	tolerance := m.options.DurationTolerance
+	if diff <= tolerance {
+		return 1
+	}
+	// linear falloff to zero at four times the tolerance
+	if diff >= 4*tolerance {
+		return 0
+	}
+
+	return 1 - float64(diff-tolerance)/float64(3*tolerance)
+}
@@ -0,0 +1,198 @@
+package matcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitVersion(t *testing.T) {
+	tests := []struct {
+		title   string
+		base    string
+		version string
+	}{
+		{"Song", "Song", ""},
+		{"Song (feat. Artist)", "Song", ""},
+		{"Song [ft. Artist & Other]", "Song", ""},
+		{"Song - feat. Artist", "Song", ""},
+		{"Song (Featuring Artist) - Remastered 2011", "Song", "Remastered 2011"},
+		{"Song - Remastered 2011", "Song", "Remastered 2011"},
+		{"Song (Live)", "Song", "Live"},
+		{"Song [Radio Edit]", "Song", "Radio Edit"},
+		{"Song (Interlude)", "Song (Interlude)", ""},
+		{"Feat of Strength", "Feat of Strength", ""},
+		{"Ft Worth Blues", "Ft Worth Blues", ""},
+		{"Love feat. Nobody", "Love feat. Nobody", ""},
+		{"Staying Alive", "Staying Alive", ""},
+		{"Special Delivery - Editor's Cut", "Special Delivery - Editor's Cut", ""},
+		{"Song (Credits)", "Song (Credits)", ""},
+		{"Song - Remastered", "Song", "Remastered"},
+		{"Song (Live at Wembley)", "Song", "Live at Wembley"},
+		{"Song [Remixes]", "Song", "Remixes"},
+	}
+
+	for _, test := range tests {
+		base, version := SplitVersion(test.title)
+		if base != test.base || version != test.version {
+			t.Errorf("SplitVersion(%q) = %q, %q, want %q, %q", test.title, base, version, test.base, test.version)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Beyoncé", "beyonce"},
+		{"Simon & Garfunkel", "simon and garfunkel"},
+		{"  Don't   Stop!  ", "don t stop"},
+	}
+
+	for _, test := range tests {
+		if got := Normalize(test.in); got != test.want {
+			t.Errorf("Normalize(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	song := Track{
+		ISRC:     "USABC1234567",
+		Title:    "Song",
+		Artists:  []string{"Artist"},
+		Album:    "Album",
+		Duration: 200 * time.Second,
+	}
+
+	tests := []struct {
+		name      string
+		candidate Track
+		method    Method
+		// the score must be at least min and below max
+		min float64
+		max float64
+	}{
+		{
+			name:      "same isrc",
+			candidate: Track{ISRC: "usabc1234567", Title: "Other Title"},
+			method:    MethodISRC,
+			min:       1,
+			max:       1.01,
+		},
+		{
+			name:      "same isrc explicit mismatch",
+			candidate: Track{ISRC: "USABC1234567", Explicit: true},
+			method:    MethodISRC,
+			min:       0.95,
+			max:       0.96,
+		},
+		{
+			name:      "exact metadata",
+			candidate: Track{Title: "Song", Artists: []string{"Artist"}, Album: "Album", Duration: 200 * time.Second},
+			method:    MethodMetadata,
+			min:       1,
+			max:       1.01,
+		},
+		{
+			name:      "featured artist and remaster",
+			candidate: Track{Title: "Song (feat. Guest) - Remastered 2011", Artists: []string{"Artist", "Guest"}, Album: "Album (Deluxe Edition)", Duration: 201 * time.Second},
+			method:    MethodMetadata,
+			min:       0.85,
+			max:       1,
+		},
+		{
+			name:      "duration within tolerance",
+			candidate: Track{Title: "Song", Artists: []string{"Artist"}, Album: "Album", Duration: 203 * time.Second},
+			method:    MethodMetadata,
+			min:       1,
+			max:       1.01,
+		},
+		{
+			name:      "duration far off",
+			candidate: Track{Title: "Song", Artists: []string{"Artist"}, Album: "Album", Duration: 240 * time.Second},
+			method:    MethodMetadata,
+			min:       0.85,
+			max:       0.86,
+		},
+		{
+			name:      "live version",
+			candidate: Track{Title: "Song (Live)", Artists: []string{"Artist"}, Album: "Album", Duration: 200 * time.Second},
+			method:    MethodMetadata,
+			min:       0.8,
+			max:       0.81,
+		},
+		{
+			name:      "wrong artist",
+			candidate: Track{Title: "Song", Artists: []string{"Karaoke Band"}, Album: "Album", Duration: 200 * time.Second},
+			method:    MethodMetadata,
+			min:       0,
+			max:       0.01,
+		},
+	}
+
+	m := Default()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score, method := m.Score(song, test.candidate)
+			if method != test.method {
+				t.Errorf("method = %s, want %s", method, test.method)
+			}
+			if score < test.min || score >= test.max {
+				t.Errorf("score = %.3f, want between %.2f and %.2f", score, test.min, test.max)
+			}
+		})
+	}
+}
+
+func TestBest(t *testing.T) {
+	source := Track{Title: "Song", Artists: []string{"Artist"}, Album: "Album", Duration: 200 * time.Second}
+
+	tests := []struct {
+		name       string
+		candidates []Track
+		want       int
+	}{
+		{
+			name: "picks the closest",
+			candidates: []Track{
+				{Title: "Song (Live)", Artists: []string{"Artist"}, Duration: 260 * time.Second},
+				{Title: "Song", Artists: []string{"Artist"}, Album: "Album", Duration: 201 * time.Second},
+			},
+			want: 1,
+		},
+		{
+			name: "rejects a cover with the same title",
+			candidates: []Track{
+				{Title: "Song", Artists: []string{"Someone Else"}, Album: "Album", Duration: 200 * time.Second},
+			},
+			want: -1,
+		},
+		{
+			name: "rejects a different title",
+			candidates: []Track{
+				{Title: "Another Song Entirely", Artists: []string{"Artist"}, Album: "Album", Duration: 245 * time.Second},
+			},
+			want: -1,
+		},
+		{
+			name:       "no candidates",
+			candidates: nil,
+			want:       -1,
+		},
+	}
+
+	m := Default()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			best := m.Best(source, test.candidates)
+			got := -1
+			if best != nil {
+				got = best.Index
+			}
+			if got != test.want {
+				t.Errorf("best = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package matcher
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// "(feat. Artist)", "[ft. Artist]", only in brackets as titles such as "Feat of Strength" start with the same words
+	featuringBracketRegex = regexp.MustCompile(`(?i)\s*[\(\[]\s*(feat|ft|featuring)\b\.?\s+[^\)\]]*[\)\]]`)
+	// "Song - feat. Artist"
+	featuringDashRegex = regexp.MustCompile(`(?i)\s+-\s+(feat|ft|featuring)\b\.?\s+.*$`)
+	// "Song (Live)", "Song [Remastered 2011]"
+	bracketRegex = regexp.MustCompile(`\s*[\(\[]([^\)\]]*)[\)\]]`)
+	// "Song - Remastered 2011", "Song - Live at Wembley"
+	dashSuffixRegex = regexp.MustCompile(`\s+-\s+(.*)$`)
+	// words which mark a bracket or dash suffix as a version rather than part of the title
+	versionKeywords = []string{"remaster", "live", "version", "edit", "mix", "remix", "mono", "stereo", "acoustic", "demo", "instrumental", "deluxe", "explicit", "clean", "edition", "bonus", "session", "take", "unplugged"}
+)
+
+// SplitVersion splits a title such as "Song - Remastered 2011" or "Song (Live)" into the
+// base title and the version. Featured artists are removed from the title.
+func SplitVersion(title string) (string, string) {
+	var versions []string
+
+	title = featuringBracketRegex.ReplaceAllString(title, "")
+	title = featuringDashRegex.ReplaceAllString(title, "")
+
+	if m := dashSuffixRegex.FindStringSubmatch(title); m != nil && isVersion(m[1]) {
+		versions = append(versions, m[1])
+		title = dashSuffixRegex.ReplaceAllString(title, "")
+	}
+
+	title = bracketRegex.ReplaceAllStringFunc(title, func(b string) string {
+		inner := bracketRegex.FindStringSubmatch(b)[1]
+		if isVersion(inner) {
+			versions = append(versions, inner)
+			return ""
+		}
+		return b
+	})
+
+	return strings.TrimSpace(title), strings.Join(versions, " ")
+}
+
+func isVersion(s string) bool {
+	words := strings.Fields(Normalize(s))
+	for _, keyword := range versionKeywords {
+		if hasKeyword(words, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasKeyword reports whether one of the words is the keyword, e.g. "live", or an inflection of it such as
+// "remastered" or "remixes". Words which only contain the keyword, like "alive" or "editor", do not count.
+func hasKeyword(words []string, keyword string) bool {
+	for _, word := range words {
+		switch word {
+		case keyword, keyword + "s", keyword + "es", keyword + "ed":
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize lowercases the string, strips accents and punctuation and collapses whitespace
+func Normalize(s string) string {
+	s = norm.NFKD.String(strings.ToLower(s))
+	s = strings.ReplaceAll(s, "&", " and ")
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// drop combining marks left over from decomposition
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// NormalizeTitle returns the normalized base title without featured artists or version suffixes
+func NormalizeTitle(title string) string {
+	base, _ := SplitVersion(title)
+	return Normalize(base)
+}
+
+// NormalizeAlbum returns the normalized album title without edition suffixes
+func NormalizeAlbum(album string) string {
+	base, _ := SplitVersion(album)
+	return Normalize(base)
+}
+
+// versionTags reduces a version string to the tags relevant for telling recordings apart
+func versionTags(version string) map[string]bool {
+	tags := make(map[string]bool)
+	words := strings.Fields(Normalize(version))
+	for _, tag := range []string{"live", "acoustic", "remix", "instrumental", "demo", "edit", "remaster", "mono"} {
+		if hasKeyword(words, tag) {
+			tags[tag] = true
+		}
+	}
+	return tags
+}
+
+// tokenSimilarity returns the Jaccard similarity of the words in two normalized strings
+func tokenSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	aTokens := strings.Fields(a)
+	bTokens := strings.Fields(b)
+	if len(aTokens) == 0 || len(bTokens) == 0 {
+		return 0
+	}
+
+	set := make(map[string]bool, len(aTokens))
+	for _, t := range aTokens {
+		set[t] = true
+	}
+
+	intersection := 0
+	union := len(set)
+	seen := make(map[string]bool, len(bTokens))
+	for _, t := range bTokens {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		if set[t] {
+			intersection++
+		} else {
+			union++
+		}
+	}
+
+	return float64(intersection) / float64(union)
+}
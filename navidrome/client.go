@@ -0,0 +1,283 @@
+package navidrome
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Subsonic API version sent with every request
+	apiVersion = "1.16.1"
+	clientName = "music-utils"
+)
+
+// Client talks to the Subsonic API of a Navidrome server
+type Client struct {
+	url        string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+type Song struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Album       string `json:"album"`
+	Artist      string `json:"artist"`
+	AlbumArtist string `json:"displayAlbumArtist"`
+	Duration    int64  `json:"duration"`
+	Track       int    `json:"track"`
+	DiscNumber  int    `json:"discNumber"`
+	Year        int    `json:"year"`
+	Path        string `json:"path"`
+	// "explicit", "clean" or empty, only returned by servers supporting the OpenSubsonic extensions
+	ExplicitStatus string `json:"explicitStatus"`
+	// ISRCs are only returned by servers supporting the OpenSubsonic extensions
+	ISRC    []string     `json:"isrc"`
+	Artists []SongArtist `json:"artists"`
+}
+
+type SongArtist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type SubsonicPlaylist struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Comment   string `json:"comment"`
+	Public    bool   `json:"public"`
+	SongCount int    `json:"songCount"`
+	Entry     []Song `json:"entry"`
+}
+
+type subsonicError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type searchResult3 struct {
+	Song []Song `json:"song"`
+}
+
+type subsonicPlaylists struct {
+	Playlist []SubsonicPlaylist `json:"playlist"`
+}
+
+type subsonicResponse struct {
+	Response struct {
+		Status        string             `json:"status"`
+		Version       string             `json:"version"`
+		Error         *subsonicError     `json:"error"`
+		SearchResult3 *searchResult3     `json:"searchResult3"`
+		Playlists     *subsonicPlaylists `json:"playlists"`
+		Playlist      *SubsonicPlaylist  `json:"playlist"`
+	} `json:"subsonic-response"`
+}
+
+func NewClient(serverUrl, username, password string, httpClient *http.Client) *Client {
+	return &Client{
+		url:        strings.TrimSuffix(serverUrl, "/"),
+		username:   username,
+		password:   password,
+		httpClient: httpClient,
+	}
+}
+
+// Endpoints which only read are sent as GET requests so they are retried on server errors
+var readEndpoints = map[string]bool{
+	"ping":         true,
+	"search3":      true,
+	"getPlaylists": true,
+	"getPlaylist":  true,
+}
+
+// request sends a Subsonic API request with token authentication. Parameters of endpoints which change
+// playlists are sent as a form as playlists can have more song IDs than fit in a URL.
+func (c *Client) request(endpoint string, params url.Values) (*subsonicResponse, error) {
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	s := hex.EncodeToString(salt)
+	token := md5.Sum([]byte(c.password + s))
+
+	form := url.Values{}
+	for key, values := range params {
+		form[key] = values
+	}
+	form.Set("u", c.username)
+	form.Set("t", hex.EncodeToString(token[:]))
+	form.Set("s", s)
+	form.Set("v", apiVersion)
+	form.Set("c", clientName)
+	form.Set("f", "json")
+
+	var req *http.Request
+	var err error
+	if readEndpoints[endpoint] {
+		req, err = http.NewRequest("GET", fmt.Sprintf("%s/rest/%s?%s", c.url, endpoint, form.Encode()), nil)
+	} else {
+		req, err = http.NewRequest("POST", fmt.Sprintf("%s/rest/%s", c.url, endpoint), strings.NewReader(form.Encode()))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !readEndpoints[endpoint] {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("navidrome %s failed: %d", endpoint, resp.StatusCode)
+	}
+
+	var subsonicResp subsonicResponse
+	if err := json.Unmarshal(body, &subsonicResp); err != nil {
+		return nil, fmt.Errorf("error parsing navidrome %s response: %v", endpoint, err)
+	}
+
+	if subsonicResp.Response.Status != "ok" {
+		if subsonicResp.Response.Error != nil {
+			return nil, fmt.Errorf("navidrome %s failed: %s (%d)", endpoint, subsonicResp.Response.Error.Message, subsonicResp.Response.Error.Code)
+		}
+		return nil, fmt.Errorf("navidrome %s failed: %s", endpoint, subsonicResp.Response.Status)
+	}
+
+	return &subsonicResp, nil
+}
+
+// Ping checks the server is reachable and the credentials are valid
+func (c *Client) Ping() error {
+	_, err := c.request("ping", nil)
+	return err
+}
+
+// Search3 searches the library for songs
+func (c *Client) Search3(query string, songCount int) ([]Song, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("songCount", strconv.Itoa(songCount))
+	params.Set("albumCount", "0")
+	params.Set("artistCount", "0")
+
+	resp, err := c.request("search3", params)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Response.SearchResult3 == nil {
+		return nil, nil
+	}
+
+	return resp.Response.SearchResult3.Song, nil
+}
+
+func (c *Client) GetPlaylists() ([]SubsonicPlaylist, error) {
+	resp, err := c.request("getPlaylists", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Response.Playlists == nil {
+		return nil, nil
+	}
+
+	return resp.Response.Playlists.Playlist, nil
+}
+
+// GetPlaylist returns the playlist with its songs
+func (c *Client) GetPlaylist(id string) (*SubsonicPlaylist, error) {
+	params := url.Values{}
+	params.Set("id", id)
+
+	resp, err := c.request("getPlaylist", params)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Response.Playlist == nil {
+		return nil, fmt.Errorf("navidrome playlist %s not found", id)
+	}
+
+	return resp.Response.Playlist, nil
+}
+
+// CreatePlaylist creates a playlist with the songs
+func (c *Client) CreatePlaylist(name string, songIds []string) (*SubsonicPlaylist, error) {
+	params := url.Values{}
+	params.Set("name", name)
+	params["songId"] = songIds
+
+	resp, err := c.request("createPlaylist", params)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Response.Playlist == nil {
+		return nil, fmt.Errorf("navidrome did not return the created playlist")
+	}
+
+	return resp.Response.Playlist, nil
+}
+
+// ReplacePlaylistSongs replaces every song of the playlist
+func (c *Client) ReplacePlaylistSongs(id string, songIds []string) error {
+	params := url.Values{}
+	params.Set("playlistId", id)
+	params["songId"] = songIds
+
+	_, err := c.request("createPlaylist", params)
+	return err
+}
+
+// PlaylistUpdate holds the changes to a playlist, unset fields are left unchanged
+type PlaylistUpdate struct {
+	Name    *string
+	Comment *string
+	Public  *bool
+	// Songs appended to the playlist
+	SongIdsToAdd []string
+	// Positions of the songs to remove
+	SongIndexesToRemove []int
+}
+
+func (c *Client) UpdatePlaylist(id string, update PlaylistUpdate) error {
+	params := url.Values{}
+	params.Set("playlistId", id)
+	if update.Name != nil {
+		params.Set("name", *update.Name)
+	}
+	if update.Comment != nil {
+		params.Set("comment", *update.Comment)
+	}
+	if update.Public != nil {
+		params.Set("public", strconv.FormatBool(*update.Public))
+	}
+	params["songIdToAdd"] = update.SongIdsToAdd
+	for _, index := range update.SongIndexesToRemove {
+		params.Add("songIndexToRemove", strconv.Itoa(index))
+	}
+
+	_, err := c.request("updatePlaylist", params)
+	return err
+}
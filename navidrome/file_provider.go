@@ -0,0 +1,193 @@
+package navidrome
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zibbp/music-utils/provider"
+	"github.com/zibbp/music-utils/utils"
+)
+
+// DefaultPath is where Navidrome format playlists are saved
+const DefaultPath = "/data/navidrome"
+
+// FileProvider stores playlists as Navidrome format JSON files so they can be imported later.
+// Playlist IDs are the file names without the extension.
+type FileProvider struct {
+	path string
+}
+
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+func (p *FileProvider) Name() string {
+	return "navidrome-file"
+}
+
+func (p *FileProvider) load(playlistID string) (Playlist, error) {
+	var playlist Playlist
+	if err := utils.ReadJsonFromFile(p.path, playlistID, &playlist); err != nil {
+		return Playlist{}, fmt.Errorf("error reading navidrome playlist %s: %v", playlistID, err)
+	}
+	return playlist, nil
+}
+
+func (p *FileProvider) save(playlistID string, playlist Playlist) error {
+	return utils.WriteJsonToFile(p.path, playlistID, playlist)
+}
+
+func (p *FileProvider) ListPlaylists() ([]provider.Playlist, error) {
+	entries, err := os.ReadDir(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var playlists []provider.Playlist
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		playlist, err := p.load(id)
+		if err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, provider.Playlist{
+			ID:          id,
+			Name:        playlist.Name,
+			Description: playlist.Description,
+			TrackCount:  len(playlist.Tracks),
+		})
+	}
+
+	return playlists, nil
+}
+
+func (p *FileProvider) GetTracks(playlistID string) ([]provider.Track, error) {
+	playlist, err := p.load(playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := make([]provider.Track, 0, len(playlist.Tracks))
+	for i, navidromeTrack := range playlist.Tracks {
+		track := provider.FromMatcherTrack(navidromeTrack.ToMatcherTrack())
+		track.Position = i
+		tracks = append(tracks, track)
+	}
+
+	return tracks, nil
+}
+
+func (p *FileProvider) CreatePlaylist(playlist provider.Playlist) (provider.Playlist, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return provider.Playlist{}, err
+	}
+	id := hex.EncodeToString(b) + "_navidrome"
+
+	if err := p.save(id, Playlist{Name: playlist.Name, Description: playlist.Description, Tracks: make([]Track, 0)}); err != nil {
+		return provider.Playlist{}, err
+	}
+
+	return provider.Playlist{
+		ID:          id,
+		Name:        playlist.Name,
+		Description: playlist.Description,
+	}, nil
+}
+
+func (p *FileProvider) UpdatePlaylist(playlist provider.Playlist) error {
+	navidromePlaylist, err := p.load(playlist.ID)
+	if err != nil {
+		return err
+	}
+
+	navidromePlaylist.Name = playlist.Name
+	navidromePlaylist.Description = playlist.Description
+
+	return p.save(playlist.ID, navidromePlaylist)
+}
+
+func (p *FileProvider) AddTracks(playlistID string, tracks []provider.Track) error {
+	playlist, err := p.load(playlistID)
+	if err != nil {
+		return err
+	}
+
+	for _, track := range tracks {
+		playlist.Tracks = append(playlist.Tracks, FromMatcherTrack(track.ToMatcherTrack()))
+	}
+
+	return p.save(playlistID, playlist)
+}
+
+func (p *FileProvider) RemoveTracks(playlistID string, tracks []provider.Track) error {
+	playlist, err := p.load(playlistID)
+	if err != nil {
+		return err
+	}
+
+	remove := make(map[int]bool, len(tracks))
+	for _, track := range tracks {
+		remove[track.Position] = true
+	}
+
+	kept := make([]Track, 0, len(playlist.Tracks))
+	for i, track := range playlist.Tracks {
+		if !remove[i] {
+			kept = append(kept, track)
+		}
+	}
+	playlist.Tracks = kept
+
+	return p.save(playlistID, playlist)
+}
+
+// ReorderTracks sorts the tracks into the order of the IDs. Tracks not in trackIDs are kept at the end.
+func (p *FileProvider) ReorderTracks(playlistID string, trackIDs []string) error {
+	playlist, err := p.load(playlistID)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string][]Track)
+	for _, track := range playlist.Tracks {
+		byID[track.ID] = append(byID[track.ID], track)
+	}
+
+	ordered := make([]Track, 0, len(playlist.Tracks))
+	for _, id := range trackIDs {
+		if tracks := byID[id]; len(tracks) > 0 {
+			ordered = append(ordered, tracks[0])
+			byID[id] = tracks[1:]
+		}
+	}
+	for _, track := range playlist.Tracks {
+		if tracks := byID[track.ID]; len(tracks) > 0 {
+			ordered = append(ordered, tracks[0])
+			byID[track.ID] = tracks[1:]
+		}
+	}
+	playlist.Tracks = ordered
+
+	return p.save(playlistID, playlist)
+}
+
+// HasCatalog is false, the tracks found by Search are not Navidrome tracks
+func (p *FileProvider) HasCatalog() bool {
+	return false
+}
+
+// Search returns the track itself as there is no catalog to search, the
+// file only records the track metadata for matching on import.
+func (p *FileProvider) Search(track provider.Track) ([]provider.Track, error) {
+	return []provider.Track{track}, nil
+}
@@ -1,5 +1,11 @@
 package navidrome
 
+import (
+	"time"
+
+	"github.com/zibbp/music-utils/matcher"
+)
+
 type Playlist struct {
 	SourceId      string  `json:"source_id"`
 	DestinationId string  `json:"destination_id"`
@@ -9,10 +15,65 @@ type Playlist struct {
 }
 
 type Track struct {
-	ID       string `json:"id"`
-	Title    string `json:"title"`
-	Album    string `json:"album"`
-	Artist   string `json:"artist"`
-	Duration int64  `json:"duration"`
-	ISRC     string `json:"isrc"`
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Album  string `json:"album"`
+	Artist string `json:"artist"`
+	// All credited artists, Artist is the first of them
+	Artists     []string `json:"artists,omitempty"`
+	AlbumArtist string   `json:"album_artist,omitempty"`
+	TrackNumber int      `json:"track_number,omitempty"`
+	DiscNumber  int      `json:"disc_number,omitempty"`
+	Year        int      `json:"year,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	Explicit    bool     `json:"explicit,omitempty"`
+	Duration    int64    `json:"duration"`
+	ISRC        string   `json:"isrc"`
+}
+
+// FromMatcherTrack converts a matcher track, Artist is the first of the artists
+func FromMatcherTrack(t matcher.Track) Track {
+	artist := ""
+	if len(t.Artists) > 0 {
+		artist = t.Artists[0]
+	}
+
+	return Track{
+		ID:          t.ID,
+		Title:       t.Title,
+		Album:       t.Album,
+		Artist:      artist,
+		Artists:     t.Artists,
+		AlbumArtist: t.AlbumArtist,
+		TrackNumber: t.TrackNumber,
+		DiscNumber:  t.DiscNumber,
+		Year:        t.Year,
+		Version:     t.Version,
+		Explicit:    t.Explicit,
+		Duration:    int64(t.Duration.Round(time.Second) / time.Second),
+		ISRC:        t.ISRC,
+	}
+}
+
+// ToMatcherTrack converts the track for use with the matcher
+func (t Track) ToMatcherTrack() matcher.Track {
+	artists := t.Artists
+	if len(artists) == 0 && t.Artist != "" {
+		artists = []string{t.Artist}
+	}
+
+	return matcher.Track{
+		ID:          t.ID,
+		ISRC:        t.ISRC,
+		Title:       t.Title,
+		Version:     t.Version,
+		Artists:     artists,
+		Album:       t.Album,
+		Duration:    time.Duration(t.Duration) * time.Second,
+		Explicit:    t.Explicit,
+		AlbumArtist: t.AlbumArtist,
+		TrackNumber: t.TrackNumber,
+		DiscNumber:  t.DiscNumber,
+		Year:        t.Year,
+	}
 }
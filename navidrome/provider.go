@@ -0,0 +1,158 @@
+package navidrome
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zibbp/music-utils/matcher"
+	"github.com/zibbp/music-utils/provider"
+)
+
+// Provider adapts the Navidrome server to the common provider interface
+type Provider struct {
+	client *Client
+}
+
+func NewProvider(client *Client) *Provider {
+	return &Provider{client: client}
+}
+
+func (p *Provider) Name() string {
+	return "navidrome"
+}
+
+func (p *Provider) ListPlaylists() ([]provider.Playlist, error) {
+	subsonicPlaylists, err := p.client.GetPlaylists()
+	if err != nil {
+		return nil, err
+	}
+
+	playlists := make([]provider.Playlist, 0, len(subsonicPlaylists))
+	for _, subsonicPlaylist := range subsonicPlaylists {
+		playlists = append(playlists, provider.Playlist{
+			ID:          subsonicPlaylist.ID,
+			Name:        subsonicPlaylist.Name,
+			Description: subsonicPlaylist.Comment,
+			Public:      subsonicPlaylist.Public,
+			TrackCount:  subsonicPlaylist.SongCount,
+		})
+	}
+
+	return playlists, nil
+}
+
+func (p *Provider) GetTracks(playlistID string) ([]provider.Track, error) {
+	playlist, err := p.client.GetPlaylist(playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := make([]provider.Track, 0, len(playlist.Entry))
+	for i, song := range playlist.Entry {
+		track := provider.FromMatcherTrack(song.ToMatcherTrack())
+		track.Position = i
+		tracks = append(tracks, track)
+	}
+
+	return tracks, nil
+}
+
+func (p *Provider) CreatePlaylist(playlist provider.Playlist) (provider.Playlist, error) {
+	createdPlaylist, err := p.client.CreatePlaylist(playlist.Name, nil)
+	if err != nil {
+		return provider.Playlist{}, err
+	}
+
+	// the comment and visibility can only be set by an update
+	if err := p.client.UpdatePlaylist(createdPlaylist.ID, PlaylistUpdate{Comment: &playlist.Description, Public: &playlist.Public}); err != nil {
+		return provider.Playlist{}, err
+	}
+
+	return provider.Playlist{
+		ID:          createdPlaylist.ID,
+		Name:        playlist.Name,
+		Description: playlist.Description,
+		Public:      playlist.Public,
+	}, nil
+}
+
+func (p *Provider) UpdatePlaylist(playlist provider.Playlist) error {
+	return p.client.UpdatePlaylist(playlist.ID, PlaylistUpdate{Name: &playlist.Name, Comment: &playlist.Description, Public: &playlist.Public})
+}
+
+func (p *Provider) AddTracks(playlistID string, tracks []provider.Track) error {
+	songIds := make([]string, 0, len(tracks))
+	for _, track := range tracks {
+		songIds = append(songIds, track.ID)
+	}
+
+	return p.client.UpdatePlaylist(playlistID, PlaylistUpdate{SongIdsToAdd: songIds})
+}
+
+func (p *Provider) RemoveTracks(playlistID string, tracks []provider.Track) error {
+	indexes := make([]int, 0, len(tracks))
+	for _, track := range tracks {
+		indexes = append(indexes, track.Position)
+	}
+
+	return p.client.UpdatePlaylist(playlistID, PlaylistUpdate{SongIndexesToRemove: indexes})
+}
+
+func (p *Provider) ReorderTracks(playlistID string, trackIDs []string) error {
+	return p.client.ReplacePlaylistSongs(playlistID, trackIDs)
+}
+
+// Search looks up the track in the library by title, then by title and artist
+func (p *Provider) Search(track provider.Track) ([]provider.Track, error) {
+	title := matcher.NormalizeTitle(track.Title)
+
+	songs, err := p.client.Search3(title, 20)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(track.Artists) > 0 {
+		artistSongs, err := p.client.Search3(fmt.Sprintf("%s %s", title, track.Artists[0]), 20)
+		if err != nil {
+			return nil, err
+		}
+		songs = append(songs, artistSongs...)
+	}
+
+	tracks := make([]provider.Track, 0, len(songs))
+	for _, song := range songs {
+		tracks = append(tracks, provider.FromMatcherTrack(song.ToMatcherTrack()))
+	}
+
+	return tracks, nil
+}
+
+// ToMatcherTrack converts the song for use with the matcher
+func (s Song) ToMatcherTrack() matcher.Track {
+	artists := make([]string, 0, len(s.Artists))
+	for _, artist := range s.Artists {
+		artists = append(artists, artist.Name)
+	}
+	if len(artists) == 0 && s.Artist != "" {
+		artists = append(artists, s.Artist)
+	}
+
+	isrc := ""
+	if len(s.ISRC) > 0 {
+		isrc = s.ISRC[0]
+	}
+
+	return matcher.Track{
+		ID:          s.ID,
+		ISRC:        isrc,
+		Title:       s.Title,
+		Artists:     artists,
+		Album:       s.Album,
+		Duration:    time.Duration(s.Duration) * time.Second,
+		Explicit:    s.ExplicitStatus == "explicit",
+		AlbumArtist: s.AlbumArtist,
+		TrackNumber: s.Track,
+		DiscNumber:  s.DiscNumber,
+		Year:        s.Year,
+	}
+}
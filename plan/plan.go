@@ -0,0 +1,345 @@
+// Package plan describes the changes a command would make to playlists so they can be
+// previewed with a dry run and applied later exactly as planned.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zibbp/music-utils/playlistmap"
+	"github.com/zibbp/music-utils/provider"
+	"github.com/zibbp/music-utils/trackcache"
+)
+
+type Action string
+
+const (
+	CreatePlaylist Action = "create_playlist"
+	UpdatePlaylist Action = "update_playlist"
+	// LinkPlaylist saves the playlist mapping of an existing playlist
+	LinkPlaylist  Action = "link_playlist"
+	AddTracks     Action = "add_tracks"
+	RemoveTracks  Action = "remove_tracks"
+	ReorderTracks Action = "reorder_tracks"
+)
+
+// Playlists created by a plan are referred to by a placeholder ID until the plan is applied
+const newPlaylistPrefix = "new:"
+
+type Step struct {
+	Action   Action `json:"action"`
+	Provider string `json:"provider"`
+	// Playlist is the target playlist. For updates and creates it has the new name, description and visibility.
+	Playlist provider.Playlist `json:"playlist"`
+	// Previous name and description of an updated playlist
+	PreviousName        string `json:"previous_name,omitempty"`
+	PreviousDescription string `json:"previous_description,omitempty"`
+	// Source playlist recorded in the playlist mappings when the playlist is created or linked
+	SourceProvider   string `json:"source_provider,omitempty"`
+	SourcePlaylistID string `json:"source_playlist_id,omitempty"`
+	// Tracks to add or remove, removed tracks are identified by their position
+	Tracks []provider.Track `json:"tracks,omitempty"`
+	// Track IDs in their new order
+	Order []string `json:"order,omitempty"`
+}
+
+type Plan struct {
+	Command string `json:"command"`
+	// Path of the files the plan was made from, if any
+	Path      string    `json:"path,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Steps     []Step    `json:"steps"`
+	created   int
+}
+
+func New(command string) *Plan {
+	return &Plan{
+		Command:   command,
+		CreatedAt: time.Now().UTC(),
+		Steps:     make([]Step, 0),
+	}
+}
+
+// Load reads a plan saved as JSON
+func Load(path string) (*Plan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("error parsing plan: %v", err)
+	}
+
+	return &p, nil
+}
+
+func (p *Plan) Add(step Step) {
+	p.Steps = append(p.Steps, step)
+}
+
+// NewPlaylistID returns a placeholder ID for a playlist created by the plan
+func (p *Plan) NewPlaylistID() string {
+	p.created++
+	return fmt.Sprintf("%s%d", newPlaylistPrefix, p.created)
+}
+
+// IsNewPlaylist reports whether the ID is a placeholder for a playlist created by a plan
+func IsNewPlaylist(id string) bool {
+	return strings.HasPrefix(id, newPlaylistPrefix)
+}
+
+// Providers returns the providers changed by the plan
+func (p *Plan) Providers() []string {
+	var providers []string
+	for _, step := range p.Steps {
+		if !slices.Contains(providers, step.Provider) {
+			providers = append(providers, step.Provider)
+		}
+	}
+	return providers
+}
+
+// Print writes the plan as a table or, with the json format, as JSON which can be applied later
+func (p *Plan) Print(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case "", "table":
+	default:
+		return fmt.Errorf("unknown plan format %s, must be table or json", format)
+	}
+
+	if len(p.Steps) == 0 {
+		fmt.Fprintln(w, "No changes")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Action", "Provider", "Playlist", "Change"}, "\t"))
+	for _, step := range p.Steps {
+		name := step.Playlist.Name
+		if name == "" {
+			name = step.Playlist.ID
+		}
+
+		rows := [][]string{}
+		switch step.Action {
+		case CreatePlaylist:
+			change := fmt.Sprintf("public: %s", strconv.FormatBool(step.Playlist.Public))
+			if step.Playlist.Description != "" {
+				change = fmt.Sprintf("description: %q, %s", step.Playlist.Description, change)
+			}
+			rows = append(rows, []string{"create", step.Provider, name, change})
+		case UpdatePlaylist:
+			var changes []string
+			if step.PreviousName != step.Playlist.Name {
+				changes = append(changes, fmt.Sprintf("rename from %q", step.PreviousName))
+			}
+			if step.PreviousDescription != step.Playlist.Description {
+				changes = append(changes, fmt.Sprintf("description %q -> %q", step.PreviousDescription, step.Playlist.Description))
+			}
+			if len(changes) == 0 {
+				changes = append(changes, fmt.Sprintf("public: %s", strconv.FormatBool(step.Playlist.Public)))
+			}
+			rows = append(rows, []string{"update", step.Provider, name, strings.Join(changes, ", ")})
+		case LinkPlaylist:
+			rows = append(rows, []string{"link", step.Provider, name, fmt.Sprintf("%s playlist %s", step.SourceProvider, step.SourcePlaylistID)})
+		case AddTracks:
+			rows = append(rows, []string{"add", step.Provider, name, fmt.Sprintf("%d tracks", len(step.Tracks))})
+			for _, track := range step.Tracks {
+				rows = append(rows, []string{"", "", "", "+ " + track.String()})
+			}
+		case RemoveTracks:
+			rows = append(rows, []string{"remove", step.Provider, name, fmt.Sprintf("%d tracks", len(step.Tracks))})
+			for _, track := range step.Tracks {
+				rows = append(rows, []string{"", "", "", fmt.Sprintf("- %s (position %d)", track, track.Position+1)})
+			}
+		case ReorderTracks:
+			rows = append(rows, []string{"reorder", step.Provider, name, fmt.Sprintf("%d tracks", len(step.Order))})
+		}
+
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	}
+
+	return tw.Flush()
+}
+
+// Apply executes the steps in order. Removals are checked against the current playlist first so
+// a plan made before the playlist changed does not remove the wrong tracks. When a step fails the
+// remaining steps of that playlist are skipped. Tracks which could not be added are removed from the track cache.
+func (p *Plan) Apply(providers map[string]provider.Provider, store *playlistmap.Store, trackCache *trackcache.Cache) error {
+	// IDs of the playlists created by the plan
+	created := make(map[string]string)
+	failed := make(map[string]bool)
+
+	for _, step := range p.Steps {
+		key := step.Provider + ":" + step.Playlist.ID
+		if failed[key] {
+			log.Warn().Str("playlist", step.Playlist.Name).Str("action", string(step.Action)).Msg("skipping step of failed playlist")
+			continue
+		}
+
+		if err := applyStep(step, providers, store, trackCache, created); err != nil {
+			log.Error().Err(err).Str("playlist", step.Playlist.Name).Msg("error applying plan step")
+			failed[key] = true
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d playlists failed to apply", len(failed))
+	}
+
+	return nil
+}
+
+func applyStep(step Step, providers map[string]provider.Provider, store *playlistmap.Store, trackCache *trackcache.Cache, created map[string]string) error {
+	destination, ok := providers[step.Provider]
+	if !ok {
+		return fmt.Errorf("unknown provider %s", step.Provider)
+	}
+
+	playlist := step.Playlist
+	if IsNewPlaylist(playlist.ID) && step.Action != CreatePlaylist {
+		id, ok := created[playlist.ID]
+		if !ok {
+			return fmt.Errorf("playlist %s is not created by the plan", playlist.ID)
+		}
+		playlist.ID = id
+	}
+
+	switch step.Action {
+	case CreatePlaylist:
+		createdPlaylist, err := destination.CreatePlaylist(playlist)
+		if err != nil {
+			return fmt.Errorf("error creating %s playlist %s: %v", step.Provider, playlist.Name, err)
+		}
+		created[playlist.ID] = createdPlaylist.ID
+		log.Info().Str("playlist", playlist.Name).Str("id", createdPlaylist.ID).Msgf("created %s playlist", step.Provider)
+
+		if step.SourceProvider != "" {
+			store.Set(step.SourceProvider, step.SourcePlaylistID, step.Provider, createdPlaylist.ID)
+			if err := store.Save(); err != nil {
+				return fmt.Errorf("error saving playlist mappings: %v", err)
+			}
+		}
+	case UpdatePlaylist:
+		if err := destination.UpdatePlaylist(playlist); err != nil {
+			return fmt.Errorf("error updating %s playlist %s: %v", step.Provider, playlist.Name, err)
+		}
+		log.Info().Str("playlist", playlist.Name).Msgf("updated %s playlist", step.Provider)
+	case LinkPlaylist:
+		store.Set(step.SourceProvider, step.SourcePlaylistID, step.Provider, playlist.ID)
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("error saving playlist mappings: %v", err)
+		}
+	case AddTracks:
+		before, err := trackCounts(destination, playlist)
+		if err != nil {
+			return err
+		}
+		if err := destination.AddTracks(playlist.ID, step.Tracks); err != nil {
+			return fmt.Errorf("error adding tracks to %s playlist %s: %v", step.Provider, playlist.Name, err)
+		}
+		if err := checkAddedTracks(destination, playlist, step.Tracks, before, trackCache); err != nil {
+			return err
+		}
+		log.Info().Str("playlist", playlist.Name).Int("tracks", len(step.Tracks)).Msgf("added tracks to %s playlist", step.Provider)
+	case RemoveTracks:
+		if err := checkRemovedTracks(destination, playlist, step.Tracks); err != nil {
+			return err
+		}
+		if err := destination.RemoveTracks(playlist.ID, step.Tracks); err != nil {
+			return fmt.Errorf("error removing tracks from %s playlist %s: %v", step.Provider, playlist.Name, err)
+		}
+		log.Info().Str("playlist", playlist.Name).Int("tracks", len(step.Tracks)).Msgf("removed tracks from %s playlist", step.Provider)
+	case ReorderTracks:
+		if err := destination.ReorderTracks(playlist.ID, step.Order); err != nil {
+			return fmt.Errorf("error reordering %s playlist %s: %v", step.Provider, playlist.Name, err)
+		}
+		log.Info().Str("playlist", playlist.Name).Msgf("reordered %s playlist", step.Provider)
+	default:
+		return fmt.Errorf("unknown plan action %s", step.Action)
+	}
+
+	return nil
+}
+
+// checkRemovedTracks makes sure the tracks are still at the planned positions
+func checkRemovedTracks(destination provider.Provider, playlist provider.Playlist, tracks []provider.Track) error {
+	current, err := destination.GetTracks(playlist.ID)
+	if err != nil {
+		return fmt.Errorf("error fetching %s playlist %s: %v", destination.Name(), playlist.Name, err)
+	}
+
+	positions := make(map[int]string, len(current))
+	for _, track := range current {
+		positions[track.Position] = track.ID
+	}
+
+	for _, track := range tracks {
+		if positions[track.Position] != track.ID {
+			return fmt.Errorf("%s playlist %s has changed since the plan was made, %s is no longer at position %d", destination.Name(), playlist.Name, track, track.Position+1)
+		}
+	}
+
+	return nil
+}
+
+// checkAddedTracks reports tracks which were not added, usually because they are no longer available, by comparing
+// the number of times each track is in the playlist with before. Their cached mappings are deleted so the next
+// transfer searches for them again.
+func checkAddedTracks(destination provider.Provider, playlist provider.Playlist, tracks []provider.Track, before map[string]int, trackCache *trackcache.Cache) error {
+	after, err := trackCounts(destination, playlist)
+	if err != nil {
+		return err
+	}
+
+	added := make(map[string]int, len(after))
+	for id, count := range after {
+		added[id] = count - before[id]
+	}
+	for _, track := range tracks {
+		if added[track.ID] > 0 {
+			added[track.ID]--
+			continue
+		}
+		log.Warn().Str("playlist", playlist.Name).Str("id", track.ID).Str("track", track.String()).Str("isrc", track.ISRC).Msg("track was not added, it may no longer be available")
+		if err := trackCache.Delete(destination.Name(), track.ID); err != nil {
+			log.Warn().Err(err).Str("id", track.ID).Msg("error deleting track from track cache")
+		}
+	}
+
+	return nil
+}
+
+// trackCounts returns the number of times each track is in the playlist
+func trackCounts(destination provider.Provider, playlist provider.Playlist) (map[string]int, error) {
+	current, err := destination.GetTracks(playlist.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s playlist %s: %v", destination.Name(), playlist.Name, err)
+	}
+
+	count := make(map[string]int, len(current))
+	for _, track := range current {
+		count[track.ID]++
+	}
+
+	return count, nil
+}
@@ -0,0 +1,110 @@
+// Package playlistmap stores which playlists on different providers are copies of each other.
+package playlistmap
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zibbp/music-utils/utils"
+)
+
+// DefaultPath of the mapping file
+const DefaultPath = "/data/state/playlist_mappings.json"
+
+// Mapping links a source playlist to the playlist it is copied to
+type Mapping struct {
+	Source        string    `json:"source"`
+	SourceID      string    `json:"source_id"`
+	Destination   string    `json:"destination"`
+	DestinationID string    `json:"destination_id"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type key struct {
+	source, sourceID, destination string
+}
+
+type Store struct {
+	path     string
+	mappings map[key]Mapping
+}
+
+// Load reads the mappings at path. A missing file is an empty store.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, mappings: make(map[key]Mapping)}
+
+	var mappings []Mapping
+	err := utils.ReadJsonFromFile(filepath.Dir(path), strings.TrimSuffix(filepath.Base(path), ".json"), &mappings)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, m := range mappings {
+		s.mappings[key{m.Source, m.SourceID, m.Destination}] = m
+	}
+
+	return s, nil
+}
+
+// Save writes the mappings back to the file
+func (s *Store) Save() error {
+	return utils.WriteJsonToFile(filepath.Dir(s.path), strings.TrimSuffix(filepath.Base(s.path), ".json"), s.List())
+}
+
+// Get returns the ID of the destination playlist the source playlist is mapped to
+func (s *Store) Get(source, sourceID, destination string) (string, bool) {
+	m, ok := s.mappings[key{source, sourceID, destination}]
+	return m.DestinationID, ok
+}
+
+// Set maps the source playlist to the destination playlist, replacing any existing mapping
+func (s *Store) Set(source, sourceID, destination, destinationID string) {
+	s.mappings[key{source, sourceID, destination}] = Mapping{
+		Source:        source,
+		SourceID:      sourceID,
+		Destination:   destination,
+		DestinationID: destinationID,
+		UpdatedAt:     time.Now().UTC(),
+	}
+}
+
+// Unset removes the mapping of the source playlist, returning false if there was none
+func (s *Store) Unset(source, sourceID, destination string) bool {
+	k := key{source, sourceID, destination}
+	if _, ok := s.mappings[k]; !ok {
+		return false
+	}
+	delete(s.mappings, k)
+	return true
+}
+
+// List returns the mappings sorted by provider and source ID
+func (s *Store) List() []Mapping {
+	mappings := make([]Mapping, 0, len(s.mappings))
+	for _, m := range s.mappings {
+		mappings = append(mappings, m)
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		if mappings[i].Source != mappings[j].Source {
+			return mappings[i].Source < mappings[j].Source
+		}
+		if mappings[i].Destination != mappings[j].Destination {
+			return mappings[i].Destination < mappings[j].Destination
+		}
+		return mappings[i].SourceID < mappings[j].SourceID
+	})
+	return mappings
+}
+
+// Count returns the number of mappings from source to destination
+func (s *Store) Count(source, destination string) int {
+	count := 0
+	for _, m := range s.mappings {
+		if m.Source == source && m.Destination == destination {
+			count++
+		}
+	}
+	return count
+}
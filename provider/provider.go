@@ -0,0 +1,106 @@
+// Package provider defines a common playlist and track model shared by the music services
+// so commands can work with any pair of them.
+package provider
+
+import (
+	"time"
+
+	"github.com/zibbp/music-utils/matcher"
+)
+
+type Playlist struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	Public        bool   `json:"public"`
+	Collaborative bool   `json:"collaborative,omitempty"`
+	TrackCount    int    `json:"track_count,omitempty"`
+}
+
+type Track struct {
+	// ID of the track on the provider
+	ID       string        `json:"id"`
+	ISRC     string        `json:"isrc,omitempty"`
+	Title    string        `json:"title"`
+	Version  string        `json:"version,omitempty"`
+	Artists  []string      `json:"artists,omitempty"`
+	Album    string        `json:"album,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Explicit bool          `json:"explicit,omitempty"`
+	// Edition details, kept so they survive a plan saved as JSON
+	AlbumArtist string `json:"album_artist,omitempty"`
+	TrackNumber int    `json:"track_number,omitempty"`
+	DiscNumber  int    `json:"disc_number,omitempty"`
+	Year        int    `json:"year,omitempty"`
+	// Position of the track in the playlist it was fetched from
+	Position int `json:"position"`
+}
+
+// Provider is implemented by each music service
+type Provider interface {
+	// Name of the provider, e.g. "spotify"
+	Name() string
+	ListPlaylists() ([]Playlist, error)
+	GetTracks(playlistID string) ([]Track, error)
+	// CreatePlaylist creates a playlist with the name, description and visibility of playlist
+	CreatePlaylist(playlist Playlist) (Playlist, error)
+	// UpdatePlaylist sets the name, description and visibility of the playlist with the ID
+	UpdatePlaylist(playlist Playlist) error
+	// AddTracks appends the tracks to the playlist in order
+	AddTracks(playlistID string, tracks []Track) error
+	// RemoveTracks removes the tracks, as returned by GetTracks, from the playlist
+	RemoveTracks(playlistID string, tracks []Track) error
+	// ReorderTracks puts the tracks of the playlist in the order of the IDs
+	ReorderTracks(playlistID string, trackIDs []string) error
+	// Search returns candidate tracks on the provider for a track from another provider
+	Search(track Track) ([]Track, error)
+}
+
+// HasCatalog reports whether Search finds tracks in a catalog of the provider. Providers without one, such as
+// playlist files, implement HasCatalog to return false and their matches are not cached.
+func HasCatalog(p Provider) bool {
+	c, ok := p.(interface{ HasCatalog() bool })
+	return !ok || c.HasCatalog()
+}
+
+func (t Track) String() string {
+	if len(t.Artists) == 0 {
+		return t.Title
+	}
+	return t.Artists[0] + " - " + t.Title
+}
+
+func (t Track) ToMatcherTrack() matcher.Track {
+	return matcher.Track{
+		ID:          t.ID,
+		ISRC:        t.ISRC,
+		Title:       t.Title,
+		Version:     t.Version,
+		Artists:     t.Artists,
+		Album:       t.Album,
+		Duration:    t.Duration,
+		Explicit:    t.Explicit,
+		AlbumArtist: t.AlbumArtist,
+		TrackNumber: t.TrackNumber,
+		DiscNumber:  t.DiscNumber,
+		Year:        t.Year,
+	}
+}
+
+// FromMatcherTrack converts a matcher track to a provider track
+func FromMatcherTrack(t matcher.Track) Track {
+	return Track{
+		ID:          t.ID,
+		ISRC:        t.ISRC,
+		Title:       t.Title,
+		Version:     t.Version,
+		Artists:     t.Artists,
+		Album:       t.Album,
+		Duration:    t.Duration,
+		Explicit:    t.Explicit,
+		AlbumArtist: t.AlbumArtist,
+		TrackNumber: t.TrackNumber,
+		DiscNumber:  t.DiscNumber,
+		Year:        t.Year,
+	}
+}
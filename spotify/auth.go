@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 	"github.com/zmb3/spotify/v2"
@@ -14,6 +16,15 @@ import (
 var (
 	ch    = make(chan *spotify.Client)
 	state = "music-utils"
+	// Scopes requested from the user. Saved tokens missing any of these are re-authorized.
+	scopes = []string{
+		spotifyauth.ScopeUserReadPrivate,
+		spotifyauth.ScopePlaylistReadPrivate,
+		spotifyauth.ScopeUserLibraryRead,
+		spotifyauth.ScopeUserFollowRead,
+		spotifyauth.ScopePlaylistModifyPublic,
+		spotifyauth.ScopePlaylistModifyPrivate,
+	}
 )
 
 func (s *Service) authFlow() (*spotify.Client, error) {
@@ -24,8 +35,8 @@ func (s *Service) authFlow() (*spotify.Client, error) {
 
 	// Check if Spotify access and refresh token is set
 	// If set, fetch and return client
-	if s.config.Get().Spotify.AccessToken == "" || s.config.Get().Spotify.RefreshToken == "" {
-		log.Warn().Msg("Spotify access token and refresh token not set")
+	if s.config.Get().Spotify.AccessToken == "" || s.config.Get().Spotify.RefreshToken == "" || !hasScopes(s.config.Get().Spotify.Scopes) {
+		log.Warn().Msg("Spotify access token and refresh token not set or missing required scopes")
 		client, err := s.auth()
 		if err != nil {
 			return nil, fmt.Errorf("error authenticating with Spotify: %w", err)
@@ -45,9 +56,9 @@ func (s *Service) authFlow() (*spotify.Client, error) {
 	spotClientID := s.clientId
 	spotClientSecret := s.clientSecret
 	redirectURI := s.clientRedirectUri
-	auth := spotifyauth.New(spotifyauth.WithClientID(spotClientID), spotifyauth.WithClientSecret(spotClientSecret), spotifyauth.WithRedirectURL(redirectURI), spotifyauth.WithScopes(spotifyauth.ScopeUserReadPrivate, spotifyauth.ScopePlaylistReadPrivate))
+	auth := spotifyauth.New(spotifyauth.WithClientID(spotClientID), spotifyauth.WithClientSecret(spotClientSecret), spotifyauth.WithRedirectURL(redirectURI), spotifyauth.WithScopes(scopes...))
 
-	client := spotify.New(auth.Client(context.Background(), tok))
+	client := spotify.New(s.oauthClient(context.Background(), auth, tok))
 
 	newTok, _ := client.Token()
 	c := s.config.Get()
@@ -74,7 +85,7 @@ func (s *Service) auth() (*spotify.Client, error) {
 	spotClientID := s.clientId
 	spotClientSecret := s.clientSecret
 	redirectURI := s.clientRedirectUri
-	auth := spotifyauth.New(spotifyauth.WithClientID(spotClientID), spotifyauth.WithClientSecret(spotClientSecret), spotifyauth.WithRedirectURL(redirectURI), spotifyauth.WithScopes(spotifyauth.ScopeUserReadPrivate, spotifyauth.ScopePlaylistReadPrivate))
+	auth := spotifyauth.New(spotifyauth.WithClientID(spotClientID), spotifyauth.WithClientSecret(spotClientSecret), spotifyauth.WithRedirectURL(redirectURI), spotifyauth.WithScopes(scopes...))
 	// Start an HTTP server
 	http.HandleFunc("/callback", s.completeAuth)
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
@@ -104,9 +115,9 @@ func (s *Service) completeAuth(w http.ResponseWriter, r *http.Request) {
 	spotClientID := s.clientId
 	spotClientSecret := s.clientSecret
 	redirectURI := s.clientRedirectUri
-	auth := spotifyauth.New(spotifyauth.WithClientID(spotClientID), spotifyauth.WithClientSecret(spotClientSecret), spotifyauth.WithRedirectURL(redirectURI), spotifyauth.WithScopes(spotifyauth.ScopeUserReadPrivate, spotifyauth.ScopePlaylistReadPrivate))
+	auth := spotifyauth.New(spotifyauth.WithClientID(spotClientID), spotifyauth.WithClientSecret(spotClientSecret), spotifyauth.WithRedirectURL(redirectURI), spotifyauth.WithScopes(scopes...))
 
-	tok, err := auth.Token(r.Context(), state, r)
+	tok, err := auth.Token(context.WithValue(r.Context(), oauth2.HTTPClient, s.httpClient), state, r)
 	if err != nil {
 		http.Error(w, "Couldn't get token", http.StatusForbidden)
 		log.Error().Msgf("Couldn't get token: %v", err)
@@ -123,6 +134,10 @@ func (s *Service) completeAuth(w http.ResponseWriter, r *http.Request) {
 	c.Spotify.RefreshToken = tok.RefreshToken
 	c.Spotify.Expiry = tok.Expiry
 	c.Spotify.TokenType = tok.TokenType
+	c.Spotify.Scopes = grantedScopes(tok)
+	if !hasScopes(c.Spotify.Scopes) {
+		log.Warn().Strs("requested", scopes).Strs("granted", c.Spotify.Scopes).Msg("not all requested spotify scopes were granted, you will be asked to log in again")
+	}
 
 	err = s.config.Update(c)
 	if err != nil {
@@ -130,6 +145,36 @@ func (s *Service) completeAuth(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// use the token to get an authenticated client
-	client := spotify.New(auth.Client(r.Context(), tok))
+	client := spotify.New(s.oauthClient(context.Background(), auth, tok))
 	ch <- client
 }
+
+// oauthClient returns a client for the token which sends requests and token refreshes with the service's HTTP client
+func (s *Service) oauthClient(ctx context.Context, auth *spotifyauth.Authenticator, tok *oauth2.Token) *http.Client {
+	client := auth.Client(context.WithValue(ctx, oauth2.HTTPClient, s.httpClient), tok)
+	client.Timeout = s.httpClient.Timeout
+
+	// kept for requests the spotify package does not support
+	s.apiClient = client
+
+	return client
+}
+
+// grantedScopes returns the scopes Spotify granted to the token. When the response does not list them the
+// requested scopes were granted.
+func grantedScopes(tok *oauth2.Token) []string {
+	if scope, ok := tok.Extra("scope").(string); ok {
+		return strings.Fields(scope)
+	}
+	return scopes
+}
+
+// hasScopes checks that all requested scopes were granted to the saved token
+func hasScopes(granted []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
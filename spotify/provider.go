@@ -0,0 +1,165 @@
+package spotify
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zibbp/music-utils/matcher"
+	"github.com/zibbp/music-utils/provider"
+	spotifyPkg "github.com/zmb3/spotify/v2"
+)
+
+// Provider adapts the Spotify service to the common provider interface.
+// Track IDs are Spotify URIs so episodes can be handled like tracks.
+type Provider struct {
+	service *Service
+	userID  string
+}
+
+func NewProvider(service *Service) *Provider {
+	return &Provider{service: service}
+}
+
+func (p *Provider) Name() string {
+	return "spotify"
+}
+
+func (p *Provider) ListPlaylists() ([]provider.Playlist, error) {
+	spotifyPlaylists, err := p.service.GetUserPlaylists()
+	if err != nil {
+		return nil, err
+	}
+
+	playlists := make([]provider.Playlist, 0, len(spotifyPlaylists))
+	for _, spotifyPlaylist := range spotifyPlaylists {
+		playlists = append(playlists, provider.Playlist{
+			ID:            spotifyPlaylist.ID.String(),
+			Name:          spotifyPlaylist.Name,
+			Description:   spotifyPlaylist.Description,
+			Public:        spotifyPlaylist.IsPublic,
+			Collaborative: spotifyPlaylist.Collaborative,
+			TrackCount:    int(spotifyPlaylist.Tracks.Total),
+		})
+	}
+
+	return playlists, nil
+}
+
+// GetTracks returns the tracks and episodes of the playlist. Unavailable items are skipped
+// but positions are those of the full playlist.
+func (p *Provider) GetTracks(playlistID string) ([]provider.Track, error) {
+	items, err := p.service.GetPlaylistItems(spotifyPkg.ID(playlistID))
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := make([]provider.Track, 0, len(items))
+	for i, item := range items {
+		var track provider.Track
+		switch {
+		case item.Track != nil:
+			track = provider.FromMatcherTrack(ToMatcherTrack(item.Track))
+			track.ID = string(item.Track.URI)
+		case item.Episode != nil:
+			track = provider.Track{
+				ID:       string(item.Episode.URI),
+				Title:    item.Episode.Name,
+				Artists:  []string{item.Episode.Show.Name},
+				Duration: time.Duration(item.Episode.Duration_ms) * time.Millisecond,
+				Explicit: item.Episode.Explicit,
+			}
+		default:
+			continue
+		}
+		track.Position = i
+		tracks = append(tracks, track)
+	}
+
+	return tracks, nil
+}
+
+func (p *Provider) CreatePlaylist(playlist provider.Playlist) (provider.Playlist, error) {
+	if p.userID == "" {
+		userID, err := p.service.CurrentUserID()
+		if err != nil {
+			return provider.Playlist{}, err
+		}
+		p.userID = userID
+	}
+
+	createdPlaylist, err := p.service.CreatePlaylist(p.userID, playlist.Name, playlist.Description, playlist.Public, playlist.Collaborative)
+	if err != nil {
+		return provider.Playlist{}, err
+	}
+
+	return provider.Playlist{
+		ID:            createdPlaylist.ID.String(),
+		Name:          playlist.Name,
+		Description:   playlist.Description,
+		Public:        playlist.Public,
+		Collaborative: playlist.Collaborative,
+	}, nil
+}
+
+func (p *Provider) UpdatePlaylist(playlist provider.Playlist) error {
+	return p.service.UpdatePlaylist(spotifyPkg.ID(playlist.ID), playlist.Name, playlist.Description, playlist.Public, playlist.Collaborative)
+}
+
+func (p *Provider) AddTracks(playlistID string, tracks []provider.Track) error {
+	uris := make([]spotifyPkg.URI, 0, len(tracks))
+	for _, track := range tracks {
+		uris = append(uris, spotifyPkg.URI(track.ID))
+	}
+
+	return p.service.AddPlaylistItems(spotifyPkg.ID(playlistID), uris)
+}
+
+func (p *Provider) RemoveTracks(playlistID string, tracks []provider.Track) error {
+	items := make([]spotifyPkg.TrackToRemove, 0, len(tracks))
+	for _, track := range tracks {
+		items = append(items, spotifyPkg.TrackToRemove{URI: track.ID, Positions: []int{track.Position}})
+	}
+
+	return p.service.RemovePlaylistItems(spotifyPkg.ID(playlistID), items)
+}
+
+func (p *Provider) ReorderTracks(playlistID string, trackIDs []string) error {
+	uris := make([]spotifyPkg.URI, 0, len(trackIDs))
+	for _, id := range trackIDs {
+		uris = append(uris, spotifyPkg.URI(id))
+	}
+
+	return p.service.ReplacePlaylistItems(spotifyPkg.ID(playlistID), uris)
+}
+
+// Search looks up the track by ISRC and by title and artist
+func (p *Provider) Search(track provider.Track) ([]provider.Track, error) {
+	var spotifyTracks []spotifyPkg.FullTrack
+
+	if track.ISRC != "" {
+		isrcTracks, err := p.service.SearchTracks(fmt.Sprintf("isrc:%s", track.ISRC), 5)
+		if err == nil {
+			spotifyTracks = append(spotifyTracks, isrcTracks...)
+		}
+	}
+
+	query := fmt.Sprintf("track:%s", matcher.NormalizeTitle(track.Title))
+	if len(track.Artists) > 0 {
+		query = fmt.Sprintf("%s artist:%s", query, track.Artists[0])
+	}
+
+	results, err := p.service.SearchTracks(query, 10)
+	if err != nil {
+		return nil, err
+	}
+	spotifyTracks = append(spotifyTracks, results...)
+
+	tracks := make([]provider.Track, 0, len(spotifyTracks))
+	for i := range spotifyTracks {
+		result := provider.FromMatcherTrack(ToMatcherTrack(&spotifyTracks[i]))
+		result.ID = string(spotifyTracks[i].URI)
+		tracks = append(tracks, result)
+	}
+
+	return tracks, nil
+}
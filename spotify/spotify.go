@@ -1,10 +1,21 @@
 package spotify
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
 
+	"github.com/rs/zerolog/log"
 	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/matcher"
+	"github.com/zibbp/music-utils/navidrome"
+	"github.com/zibbp/music-utils/utils"
 
 	spotifyPkg "github.com/zmb3/spotify/v2"
 )
@@ -15,9 +26,12 @@ type Service struct {
 	clientId          string
 	clientSecret      string
 	clientRedirectUri string
+	httpClient        *http.Client
+	apiClient         *http.Client // authenticated client
 }
 
-func Initialize(clientId, clientSecret, clientRedirectUri string, config *config.JsonConfigService) (*Service, error) {
+// Initialize creates the Spotify service. All requests, including token refreshes, are sent with httpClient.
+func Initialize(clientId, clientSecret, clientRedirectUri string, httpClient *http.Client, config *config.JsonConfigService) (*Service, error) {
 	if clientId == "" || clientSecret == "" || clientRedirectUri == "" {
 		return nil, fmt.Errorf("spotify client ID, secret and redirect URI not provided, check env vars")
 	}
@@ -26,6 +40,7 @@ func Initialize(clientId, clientSecret, clientRedirectUri string, config *config
 	s.clientId = clientId
 	s.clientSecret = clientSecret
 	s.clientRedirectUri = clientRedirectUri
+	s.httpClient = httpClient
 	s.config = config
 
 	err := s.Authenticate()
@@ -69,16 +84,41 @@ func (s *Service) GetUserPlaylists() ([]spotifyPkg.SimplePlaylist, error) {
 	return allPlaylists, nil
 }
 
-func (s *Service) GetPlaylistTracks(id spotifyPkg.ID) ([]*spotifyPkg.FullTrack, error) {
+// PlaylistItem is a playlist entry as saved in backups.
+// Episodes are kept as episodes and local files keep the metadata Spotify has for them.
+type PlaylistItem struct {
+	AddedAt string          `json:"added_at"`
+	AddedBy spotifyPkg.User `json:"added_by"`
+	IsLocal bool            `json:"is_local"`
+	// "track", "episode" or empty if the item is unavailable
+	Type    string                  `json:"type"`
+	Track   *spotifyPkg.FullTrack   `json:"track,omitempty"`
+	Episode *spotifyPkg.EpisodePage `json:"episode,omitempty"`
+}
+
+func (s *Service) GetPlaylistItems(id spotifyPkg.ID) ([]PlaylistItem, error) {
 	items, err := s.client.GetPlaylistItems(context.Background(), id)
 	if err != nil {
 		return nil, err
 	}
 
-	var allTracks []*spotifyPkg.FullTrack
+	var allItems []PlaylistItem
 	for page := 1; ; page++ {
-		for _, track := range items.Items {
-			allTracks = append(allTracks, track.Track.Track)
+		for _, item := range items.Items {
+			playlistItem := PlaylistItem{
+				AddedAt: item.AddedAt,
+				AddedBy: item.AddedBy,
+				IsLocal: item.IsLocal,
+				Track:   item.Track.Track,
+				Episode: item.Track.Episode,
+			}
+			switch {
+			case item.Track.Track != nil:
+				playlistItem.Type = "track"
+			case item.Track.Episode != nil:
+				playlistItem.Type = "episode"
+			}
+			allItems = append(allItems, playlistItem)
 		}
 		if items.Next == "" {
 			break
@@ -90,5 +130,317 @@ func (s *Service) GetPlaylistTracks(id spotifyPkg.ID) ([]*spotifyPkg.FullTrack,
 		}
 	}
 
+	return allItems, nil
+}
+
+func (s *Service) GetSavedTracks() ([]spotifyPkg.SavedTrack, error) {
+	tracks, err := s.client.CurrentUsersTracks(context.Background(), spotifyPkg.Limit(50))
+	if err != nil {
+		return nil, err
+	}
+
+	var allTracks []spotifyPkg.SavedTrack
+	for {
+		allTracks = append(allTracks, tracks.Tracks...)
+		if tracks.Next == "" {
+			break
+		}
+
+		err = s.client.NextPage(context.Background(), tracks)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return allTracks, nil
 }
+
+func (s *Service) GetSavedAlbums() ([]spotifyPkg.SavedAlbum, error) {
+	albums, err := s.client.CurrentUsersAlbums(context.Background(), spotifyPkg.Limit(50))
+	if err != nil {
+		return nil, err
+	}
+
+	var allAlbums []spotifyPkg.SavedAlbum
+	for {
+		allAlbums = append(allAlbums, albums.Albums...)
+		if albums.Next == "" {
+			break
+		}
+
+		err = s.client.NextPage(context.Background(), albums)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return allAlbums, nil
+}
+
+// GetFollowedArtists fetches the user's followed artists. This endpoint uses a cursor instead of pages.
+func (s *Service) GetFollowedArtists() ([]spotifyPkg.FullArtist, error) {
+	var allArtists []spotifyPkg.FullArtist
+
+	opts := []spotifyPkg.RequestOption{spotifyPkg.Limit(50)}
+	for {
+		artists, err := s.client.CurrentUsersFollowedArtists(context.Background(), opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		allArtists = append(allArtists, artists.Artists...)
+		if artists.Next == "" || artists.Cursor.After == "" {
+			break
+		}
+
+		opts = []spotifyPkg.RequestOption{spotifyPkg.Limit(50), spotifyPkg.After(artists.Cursor.After)}
+	}
+
+	return allArtists, nil
+}
+
+func (s *Service) GetSavedShows() ([]spotifyPkg.SavedShow, error) {
+	shows, err := s.client.CurrentUsersShows(context.Background(), spotifyPkg.Limit(50))
+	if err != nil {
+		return nil, err
+	}
+
+	var allShows []spotifyPkg.SavedShow
+	for {
+		allShows = append(allShows, shows.Shows...)
+		if shows.Next == "" {
+			break
+		}
+
+		err = s.client.NextPage(context.Background(), shows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return allShows, nil
+}
+
+// SavedPlaylist is a playlist as written by the save command
+type SavedPlaylist struct {
+	spotifyPkg.SimplePlaylist
+	Tracks []PlaylistItem `json:"tracks"`
+}
+
+func (s *Service) CurrentUserID() (string, error) {
+	user, err := s.client.CurrentUser(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	return user.ID, nil
+}
+
+func (s *Service) GetPlaylist(id string) (*spotifyPkg.FullPlaylist, error) {
+	return s.client.GetPlaylist(context.Background(), spotifyPkg.ID(id))
+}
+
+// IsNotFound reports whether the error is a Spotify API error for a resource which does not exist
+func IsNotFound(err error) bool {
+	var apiErr spotifyPkg.Error
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+func (s *Service) CreatePlaylist(userID, name, description string, public, collaborative bool) (*spotifyPkg.FullPlaylist, error) {
+	return s.client.CreatePlaylistForUser(context.Background(), userID, name, description, public, collaborative)
+}
+
+// UpdatePlaylist sets the details of the playlist. The spotify package can't change whether a playlist is collaborative.
+func (s *Service) UpdatePlaylist(id spotifyPkg.ID, name, description string, public, collaborative bool) error {
+	body, err := json.Marshal(map[string]any{
+		"name":          name,
+		"description":   description,
+		"public":        public,
+		"collaborative": collaborative,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("https://api.spotify.com/v1/playlists/%s", id), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to update playlist: %s", string(respBody))
+	}
+
+	return nil
+}
+
+// FollowsPlaylist reports whether the user follows the playlist. Deleting a playlist only unfollows it.
+func (s *Service) FollowsPlaylist(id string, userID string) (bool, error) {
+	follows, err := s.client.UserFollowsPlaylist(context.Background(), spotifyPkg.ID(id), userID)
+	if err != nil {
+		return false, err
+	}
+
+	return len(follows) > 0 && follows[0], nil
+}
+
+// Maximum number of items Spotify accepts per playlist items request
+const playlistItemsChunkSize = 100
+
+// ReplacePlaylistItems replaces all items of the playlist with the URIs, keeping their order.
+// The first chunk replaces the playlist and the rest are appended.
+func (s *Service) ReplacePlaylistItems(id spotifyPkg.ID, uris []spotifyPkg.URI) error {
+	end := min(playlistItemsChunkSize, len(uris))
+	if _, err := s.client.ReplacePlaylistItems(context.Background(), id, uris[:end]...); err != nil {
+		return err
+	}
+
+	for start := end; start < len(uris); start += playlistItemsChunkSize {
+		if err := s.addPlaylistItems(id, uris[start:min(start+playlistItemsChunkSize, len(uris))]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddPlaylistItems appends the items by URI in chunks
+func (s *Service) AddPlaylistItems(id spotifyPkg.ID, uris []spotifyPkg.URI) error {
+	for start := 0; start < len(uris); start += playlistItemsChunkSize {
+		if err := s.addPlaylistItems(id, uris[start:min(start+playlistItemsChunkSize, len(uris))]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemovePlaylistItems removes the items at the positions. The items are removed from the
+// highest position down so earlier chunks do not shift later ones.
+func (s *Service) RemovePlaylistItems(id spotifyPkg.ID, items []spotifyPkg.TrackToRemove) error {
+	for _, item := range items {
+		if len(item.Positions) == 0 {
+			return fmt.Errorf("no positions given to remove %s", item.URI)
+		}
+	}
+
+	sorted := append([]spotifyPkg.TrackToRemove(nil), items...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return slices.Max(sorted[i].Positions) > slices.Max(sorted[j].Positions)
+	})
+
+	for start := 0; start < len(sorted); start += playlistItemsChunkSize {
+		if _, err := s.client.RemoveTracksFromPlaylistOpt(context.Background(), id, sorted[start:min(start+playlistItemsChunkSize, len(sorted))], ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) SearchTracks(query string, limit int) ([]spotifyPkg.FullTrack, error) {
+	result, err := s.client.Search(context.Background(), query, spotifyPkg.SearchTypeTrack, spotifyPkg.Limit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if result.Tracks == nil {
+		return nil, nil
+	}
+
+	return result.Tracks.Tracks, nil
+}
+
+// addPlaylistItems appends items by URI. The spotify package can only add tracks by ID, this also supports episodes.
+func (s *Service) addPlaylistItems(id spotifyPkg.ID, uris []spotifyPkg.URI) error {
+	body, err := json.Marshal(map[string]any{"uris": uris})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("https://api.spotify.com/v1/playlists/%s/tracks", id), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to add items to playlist: %s", string(respBody))
+	}
+
+	return nil
+}
+
+// ToNavidromePlaylist converts a saved playlist to the Navidrome format. Episodes and unavailable items are
+// skipped, tracks without artists are reported and kept with an empty artist.
+func (s *Service) ToNavidromePlaylist(playlist *SavedPlaylist) (navidrome.Playlist, error) {
+	var np navidrome.Playlist
+	if playlist == nil {
+		return np, fmt.Errorf("playlist is nil")
+	}
+
+	np.SourceId = playlist.ID.String()
+	np.Name = playlist.Name
+	np.Description = playlist.Description
+	np.Tracks = make([]navidrome.Track, 0, len(playlist.Tracks))
+
+	for _, item := range playlist.Tracks {
+		if item.Track == nil {
+			continue
+		}
+
+		track := navidrome.FromMatcherTrack(ToMatcherTrack(item.Track))
+		// local files have no ID
+		if track.ID == "" {
+			track.ID = string(item.Track.URI)
+		}
+		if track.Artist == "" {
+			log.Warn().Str("playlist", playlist.Name).Str("id", track.ID).Str("track", track.Title).Msg("spotify track has no artists")
+		}
+
+		np.Tracks = append(np.Tracks, track)
+	}
+
+	return np, nil
+}
+
+// ToMatcherTrack converts the track for use with the matcher
+func ToMatcherTrack(track *spotifyPkg.FullTrack) matcher.Track {
+	artists := make([]string, 0, len(track.Artists))
+	for _, artist := range track.Artists {
+		artists = append(artists, artist.Name)
+	}
+
+	albumArtist := ""
+	if len(track.Album.Artists) > 0 {
+		albumArtist = track.Album.Artists[0].Name
+	}
+
+	return matcher.Track{
+		ID:          track.ID.String(),
+		ISRC:        track.ExternalIDs["isrc"],
+		Title:       track.Name,
+		Artists:     artists,
+		Album:       track.Album.Name,
+		Duration:    track.TimeDuration(),
+		Explicit:    track.Explicit,
+		AlbumArtist: albumArtist,
+		TrackNumber: int(track.TrackNumber),
+		DiscNumber:  int(track.DiscNumber),
+		Year:        utils.ReleaseYear(track.Album.ReleaseDate),
+	}
+}
@@ -11,8 +11,6 @@ import (
 
 func (s *Service) clientAuth(clientId string, clientSecret string) (string, error) {
 
-	client := &http.Client{}
-
 	params := url.Values{}
 	params.Set("grant_type", "client_credentials")
 
@@ -24,7 +22,7 @@ func (s *Service) clientAuth(clientId string, clientSecret string) (string, erro
 	req.SetBasicAuth(clientId, clientSecret)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := client.Do(req)
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -42,10 +42,11 @@ type AuthLogin struct {
 }
 
 type Refresh struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int64  `json:"expires_in"`
-	User        User   `json:"user"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	User         User   `json:"user"`
 }
 
 type User struct {
@@ -102,8 +103,6 @@ type Client struct {
 func (s *Service) getDeviceCode() (*DeviceCode, error) {
 	var deviceCode DeviceCode
 
-	client := &http.Client{}
-
 	data := url.Values{}
 	data.Set("client_id", clientId)
 	data.Set("scope", "r_usr+w_usr+w_sub")
@@ -117,7 +116,7 @@ func (s *Service) getDeviceCode() (*DeviceCode, error) {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := client.Do(req)
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +143,6 @@ func (s *Service) getDeviceCode() (*DeviceCode, error) {
 func (s *Service) tokenLogin(deviceCode DeviceCode) (*LoginResponse, error) {
 	var loginResponse LoginResponse
 
-	client := &http.Client{}
 	// Set body
 	data := url.Values{}
 	data.Set("client_id", clientId)
@@ -164,7 +162,7 @@ func (s *Service) tokenLogin(deviceCode DeviceCode) (*LoginResponse, error) {
 	// Set Headers
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := client.Do(req)
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -199,8 +197,6 @@ func (s *Service) tokenLogin(deviceCode DeviceCode) (*LoginResponse, error) {
 func (s *Service) checkSession(accessToken string) (Session, error) {
 	var session Session
 
-	client := &http.Client{}
-
 	req, err := http.NewRequest("GET", fmt.Sprintf("%s/sessions", apiURL), nil)
 	if err != nil {
 		return session, err
@@ -208,7 +204,7 @@ func (s *Service) checkSession(accessToken string) (Session, error) {
 
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	resp, err := client.Do(req)
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return session, err
 	}
@@ -235,8 +231,6 @@ func (s *Service) checkSession(accessToken string) (Session, error) {
 func (s *Service) refreshSession(refreshToken string) (*Refresh, error) {
 	var refresh Refresh
 
-	client := &http.Client{}
-
 	data := url.Values{}
 	data.Set("client_id", clientId)
 	data.Set("refresh_token", refreshToken)
@@ -254,7 +248,7 @@ func (s *Service) refreshSession(refreshToken string) (*Refresh, error) {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := client.Do(req)
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,125 @@
+package tidal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Number of favorites requested per page
+const favoritesPageSize = 100
+
+type FavoriteTrack struct {
+	Created string `json:"created"`
+	Item    Track  `json:"item"`
+}
+
+type FavoriteAlbum struct {
+	Created string `json:"created"`
+	Item    Album  `json:"item"`
+}
+
+type FavoriteArtist struct {
+	Created string `json:"created"`
+	Item    Artist `json:"item"`
+}
+
+type Mix struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	MixType   string      `json:"mixType"`
+	Title     string      `json:"title"`
+	SubTitle  string      `json:"subTitle"`
+	Images    interface{} `json:"images"`
+	DateAdded string      `json:"dateAdded"`
+}
+
+type favoritesPage[T any] struct {
+	Limit              int64 `json:"limit"`
+	Offset             int64 `json:"offset"`
+	TotalNumberOfItems int64 `json:"totalNumberOfItems"`
+	Items              []T   `json:"items"`
+}
+
+type favoriteMixesResponse struct {
+	Items  []Mix  `json:"items"`
+	Cursor string `json:"cursor"`
+}
+
+// getFavorites pages through one of the user's favorites collections
+func getFavorites[T any](s *Service, collection string) ([]T, error) {
+	items := make([]T, 0)
+
+	for offset := 0; ; {
+		body, err := s.standardHttpGetRequest(fmt.Sprintf("%s/users/%s/favorites/%s", apiURL, s.UserID, collection), map[string]string{
+			"limit":          strconv.Itoa(favoritesPageSize),
+			"offset":         strconv.Itoa(offset),
+			"order":          "DATE",
+			"orderDirection": "DESC",
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var page favoritesPage[T]
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+
+		items = append(items, page.Items...)
+
+		// pages can be shorter than the limit
+		offset += len(page.Items)
+		if len(page.Items) == 0 || int64(offset) >= page.TotalNumberOfItems {
+			break
+		}
+	}
+
+	return items, nil
+}
+
+func (s *Service) GetFavoriteTracks() ([]FavoriteTrack, error) {
+	return getFavorites[FavoriteTrack](s, "tracks")
+}
+
+func (s *Service) GetFavoriteAlbums() ([]FavoriteAlbum, error) {
+	return getFavorites[FavoriteAlbum](s, "albums")
+}
+
+func (s *Service) GetFavoriteArtists() ([]FavoriteArtist, error) {
+	return getFavorites[FavoriteArtist](s, "artists")
+}
+
+// GetFavoriteMixes fetches the user's saved mixes. Mixes are only available from the v2 API which uses a cursor.
+func (s *Service) GetFavoriteMixes() ([]Mix, error) {
+	mixes := make([]Mix, 0)
+	cursor := ""
+
+	for {
+		params := map[string]string{
+			"limit": strconv.Itoa(favoritesPageSize),
+		}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+
+		body, err := s.standardHttpGetRequest(fmt.Sprintf("%s/favorites/mixes", apiURL2), params)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp favoriteMixesResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, err
+		}
+
+		mixes = append(mixes, resp.Items...)
+
+		if resp.Cursor == "" || len(resp.Items) == 0 {
+			break
+		}
+		cursor = resp.Cursor
+	}
+
+	return mixes, nil
+}
@@ -0,0 +1,269 @@
+package tidal
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Maximum number of items sent in a single playlist items request
+const playlistItemsChunkSize = 100
+
+// DupeMode controls how Tidal handles adding a track which is already in the playlist
+type DupeMode string
+
+const (
+	DupesFail DupeMode = "FAIL"
+	DupesAdd  DupeMode = "ADD"
+	DupesSkip DupeMode = "SKIP"
+)
+
+// playlistItemsRequest sends a modification request for a playlist's items using the playlist etag.
+// The new etag of the playlist is returned so subsequent requests don't need to fetch it again.
+func (s *Service) playlistItemsRequest(method string, reqUrl string, etag string, data url.Values) (string, error) {
+	resp, err := s.do(apiRequest{
+		method:  method,
+		url:     reqUrl,
+		form:    data,
+		headers: map[string]string{"If-None-Match": etag},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return "", &playlistItemsError{StatusCode: resp.StatusCode, Body: string(resp.Body)}
+	}
+
+	return resp.Header.Get("ETag"), nil
+}
+
+type playlistItemsError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *playlistItemsError) Error() string {
+	return fmt.Sprintf("playlist items request failed: %d %s", e.StatusCode, e.Body)
+}
+
+// nextEtag returns the etag from the previous response or fetches it if the response did not include one
+func (s *Service) nextEtag(playlistId string, etag string) (string, error) {
+	if etag != "" {
+		return etag, nil
+	}
+	return s.getPlaylistEtag(playlistId)
+}
+
+// AddTracksToPlaylist appends the tracks to the playlist in chunks, carrying the playlist etag between chunks
+func (s *Service) AddTracksToPlaylist(playlistId string, trackIds []string, onDupes DupeMode) error {
+	etag, err := s.getPlaylistEtag(playlistId)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(trackIds); start += playlistItemsChunkSize {
+		end := min(start+playlistItemsChunkSize, len(trackIds))
+
+		data := url.Values{}
+		data.Set("trackIds", strings.Join(trackIds[start:end], ","))
+		data.Set("onArtifactNotFound", "SKIP")
+		data.Set("onDupes", string(onDupes))
+
+		newEtag, err := s.playlistItemsRequest("POST", fmt.Sprintf("%s/playlists/%s/items", apiURL, playlistId), etag, data)
+		if e, ok := err.(*playlistItemsError); ok && e.StatusCode == http.StatusConflict && onDupes == DupesFail {
+			// the whole chunk is rejected when one track is a duplicate, the others are added skipping the duplicates
+			log.Debug().Msgf("Some of tracks %v already exist in playlist %s, adding the others", trackIds[start:end], playlistId)
+			etag, err = s.getPlaylistEtag(playlistId)
+			if err != nil {
+				return err
+			}
+			data.Set("onDupes", string(DupesSkip))
+			newEtag, err = s.playlistItemsRequest("POST", fmt.Sprintf("%s/playlists/%s/items", apiURL, playlistId), etag, data)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add tracks to playlist: %w", err)
+		}
+
+		if end < len(trackIds) {
+			etag, err = s.nextEtag(playlistId, newEtag)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RemoveTracksFromPlaylist removes the items at the indices from the playlist.
+// Indices are removed from the highest down so earlier chunks do not shift later ones.
+func (s *Service) RemoveTracksFromPlaylist(playlistId string, indices []int) error {
+	sorted := append([]int(nil), indices...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+
+	etag, err := s.getPlaylistEtag(playlistId)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(sorted); start += playlistItemsChunkSize {
+		end := min(start+playlistItemsChunkSize, len(sorted))
+
+		newEtag, err := s.playlistItemsRequest("DELETE", fmt.Sprintf("%s/playlists/%s/items/%s", apiURL, playlistId, joinIndices(sorted[start:end])), etag, url.Values{})
+		if err != nil {
+			return fmt.Errorf("failed to remove tracks from playlist: %w", err)
+		}
+
+		if end < len(sorted) {
+			etag, err = s.nextEtag(playlistId, newEtag)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// MoveTracksInPlaylist moves the items at the indices so they start at toIndex
+func (s *Service) MoveTracksInPlaylist(playlistId string, indices []int, toIndex int) error {
+	etag, err := s.getPlaylistEtag(playlistId)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.moveTracks(playlistId, etag, indices, toIndex)
+
+	return err
+}
+
+func (s *Service) moveTracks(playlistId string, etag string, indices []int, toIndex int) (string, error) {
+	data := url.Values{}
+	data.Set("toIndex", strconv.Itoa(toIndex))
+
+	newEtag, err := s.playlistItemsRequest("POST", fmt.Sprintf("%s/playlists/%s/items/%s", apiURL, playlistId, joinIndices(indices)), etag, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to move tracks in playlist: %w", err)
+	}
+
+	return newEtag, nil
+}
+
+// MirrorPlaylistTracks makes the playlist contain exactly the tracks in trackIds in the same order.
+// Extra tracks are removed, missing tracks are appended and then items are moved into place.
+// Tracks which are unavailable on Tidal are left out.
+func (s *Service) MirrorPlaylistTracks(playlistId string, trackIds []string) error {
+	current, err := s.GetPlaylistTracks(playlistId)
+	if err != nil {
+		return err
+	}
+
+	// count how many times each track is wanted so duplicates are kept
+	wanted := make(map[string]int)
+	for _, id := range trackIds {
+		wanted[id]++
+	}
+
+	var remove []int
+	for i, track := range current.Items {
+		id := strconv.FormatInt(track.ID, 10)
+		if wanted[id] > 0 {
+			wanted[id]--
+			continue
+		}
+		remove = append(remove, i)
+	}
+
+	if len(remove) > 0 {
+		log.Debug().Msgf("removing %d tracks from playlist %s", len(remove), playlistId)
+		if err := s.RemoveTracksFromPlaylist(playlistId, remove); err != nil {
+			return err
+		}
+	}
+
+	var add []string
+	for _, id := range trackIds {
+		if wanted[id] > 0 {
+			wanted[id]--
+			add = append(add, id)
+		}
+	}
+
+	if len(add) > 0 {
+		log.Debug().Msgf("adding %d tracks to playlist %s", len(add), playlistId)
+		if err := s.AddTracksToPlaylist(playlistId, add, DupesAdd); err != nil {
+			return err
+		}
+	}
+
+	if len(remove) > 0 || len(add) > 0 {
+		// unavailable tracks are skipped by Tidal so fetch what the playlist now contains
+		current, err = s.GetPlaylistTracks(playlistId)
+		if err != nil {
+			return err
+		}
+	}
+
+	items := make([]string, 0, len(current.Items))
+	present := make(map[string]int)
+	for _, track := range current.Items {
+		id := strconv.FormatInt(track.ID, 10)
+		items = append(items, id)
+		present[id]++
+	}
+
+	// desired order of the tracks which are in the playlist
+	order := make([]string, 0, len(items))
+	for _, id := range trackIds {
+		if present[id] > 0 {
+			present[id]--
+			order = append(order, id)
+		}
+	}
+
+	etag := ""
+	for i, id := range order {
+		if items[i] == id {
+			continue
+		}
+
+		from := -1
+		for j := i + 1; j < len(items); j++ {
+			if items[j] == id {
+				from = j
+				break
+			}
+		}
+		if from == -1 {
+			continue
+		}
+
+		etag, err = s.nextEtag(playlistId, etag)
+		if err != nil {
+			return err
+		}
+		etag, err = s.moveTracks(playlistId, etag, []int{from}, i)
+		if err != nil {
+			return err
+		}
+
+		copy(items[i+1:from+1], items[i:from])
+		items[i] = id
+	}
+
+	return nil
+}
+
+func joinIndices(indices []int) string {
+	parts := make([]string, 0, len(indices))
+	for _, index := range indices {
+		parts = append(parts, strconv.Itoa(index))
+	}
+	return strings.Join(parts, ",")
+}
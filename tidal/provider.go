@@ -0,0 +1,127 @@
+package tidal
+
+import (
+	"fmt"
+
+	"github.com/zibbp/music-utils/matcher"
+	"github.com/zibbp/music-utils/provider"
+)
+
+// Provider adapts the Tidal service to the common provider interface
+type Provider struct {
+	service *Service
+}
+
+func NewProvider(service *Service) *Provider {
+	return &Provider{service: service}
+}
+
+func (p *Provider) Name() string {
+	return "tidal"
+}
+
+func (p *Provider) ListPlaylists() ([]provider.Playlist, error) {
+	tidalPlaylists, err := p.service.GetUserPlaylists()
+	if err != nil {
+		return nil, err
+	}
+
+	playlists := make([]provider.Playlist, 0, len(tidalPlaylists))
+	for _, tidalPlaylist := range tidalPlaylists {
+		playlists = append(playlists, provider.Playlist{
+			ID:          tidalPlaylist.Data.UUID,
+			Name:        tidalPlaylist.Data.Title,
+			Description: tidalPlaylist.Data.Description,
+			Public:      tidalPlaylist.Data.SharingLevel == "PUBLIC",
+			TrackCount:  tidalPlaylist.Data.NumberOfTracks,
+		})
+	}
+
+	return playlists, nil
+}
+
+func (p *Provider) GetTracks(playlistID string) ([]provider.Track, error) {
+	tidalTracks, err := p.service.GetPlaylistTracks(playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := make([]provider.Track, 0, len(tidalTracks.Items))
+	for i, tidalTrack := range tidalTracks.Items {
+		track := provider.FromMatcherTrack(tidalTrack.ToMatcherTrack())
+		track.Position = i
+		tracks = append(tracks, track)
+	}
+
+	return tracks, nil
+}
+
+// CreatePlaylist creates a private playlist, the visibility of the playlist is ignored
+func (p *Provider) CreatePlaylist(playlist provider.Playlist) (provider.Playlist, error) {
+	createdPlaylist, err := p.service.CreatePlaylist(playlist.Name, playlist.Description)
+	if err != nil {
+		return provider.Playlist{}, err
+	}
+
+	return provider.Playlist{
+		ID:          createdPlaylist.UUID,
+		Name:        playlist.Name,
+		Description: playlist.Description,
+	}, nil
+}
+
+func (p *Provider) UpdatePlaylist(playlist provider.Playlist) error {
+	return p.service.UpdatePlaylist(playlist.ID, playlist.Name, playlist.Description)
+}
+
+func (p *Provider) AddTracks(playlistID string, tracks []provider.Track) error {
+	trackIds := make([]string, 0, len(tracks))
+	for _, track := range tracks {
+		trackIds = append(trackIds, track.ID)
+	}
+
+	return p.service.AddTracksToPlaylist(playlistID, trackIds, DupesAdd)
+}
+
+func (p *Provider) RemoveTracks(playlistID string, tracks []provider.Track) error {
+	indices := make([]int, 0, len(tracks))
+	for _, track := range tracks {
+		indices = append(indices, track.Position)
+	}
+
+	return p.service.RemoveTracksFromPlaylist(playlistID, indices)
+}
+
+func (p *Provider) ReorderTracks(playlistID string, trackIDs []string) error {
+	return p.service.MirrorPlaylistTracks(playlistID, trackIDs)
+}
+
+// Search looks up the track by ISRC and by title and artist
+func (p *Provider) Search(track provider.Track) ([]provider.Track, error) {
+	var tidalTracks []Track
+
+	if track.ISRC != "" {
+		isrcTracks, err := p.service.GetTracksByISRC(track.ISRC)
+		if err == nil {
+			tidalTracks = append(tidalTracks, isrcTracks...)
+		}
+	}
+
+	query := matcher.NormalizeTitle(track.Title)
+	if len(track.Artists) > 0 {
+		query = fmt.Sprintf("%s %s", query, track.Artists[0])
+	}
+
+	results, err := p.service.SearchTracks(query, 10, 0)
+	if err != nil {
+		return nil, err
+	}
+	tidalTracks = append(tidalTracks, results.Items...)
+
+	tracks := make([]provider.Track, 0, len(tidalTracks))
+	for _, tidalTrack := range tidalTracks {
+		tracks = append(tracks, provider.FromMatcherTrack(tidalTrack.ToMatcherTrack()))
+	}
+
+	return tracks, nil
+}
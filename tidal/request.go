@@ -0,0 +1,185 @@
+package tidal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zibbp/music-utils/httpclient"
+)
+
+// Refresh the access token when it expires within this window
+const tokenRefreshWindow = 5 * time.Minute
+
+type apiRequest struct {
+	method  string
+	url     string
+	query   url.Values
+	form    url.Values
+	headers map[string]string
+	// use the client credentials token instead of the user's token
+	clientAuth bool
+	// the request creates something so it is not retried after it may have reached the server
+	notIdempotent bool
+}
+
+type apiResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// do sends an authenticated request to the Tidal API. The user's access token is refreshed
+// before it expires and the request is replayed once if the API responds with 401.
+// The country code is added to every request.
+func (s *Service) do(r apiRequest) (*apiResponse, error) {
+	if !r.clientAuth {
+		if err := s.refreshIfExpiring(); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := s.send(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		log.Debug().Str("url", r.url).Msg("tidal request unauthorized, refreshing token")
+		if r.clientAuth {
+			err = s.refreshClientToken()
+		} else {
+			err = s.refreshAccessToken()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = s.send(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+func (s *Service) send(r apiRequest) (*apiResponse, error) {
+	var body io.Reader
+	if r.form != nil {
+		body = strings.NewReader(r.form.Encode())
+	}
+
+	ctx := context.Background()
+	if r.notIdempotent {
+		ctx = httpclient.NotIdempotent(ctx)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set Headers
+	req.Header.Set("Accept", "application/json")
+	if r.form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	s.mu.Lock()
+	if r.clientAuth {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.ClientAccessToken))
+	} else {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.AccessToken))
+	}
+	s.mu.Unlock()
+	for key, value := range r.headers {
+		req.Header.Set(key, value)
+	}
+
+	// Set Query Params
+	q := url.Values{}
+	for key, values := range r.query {
+		q[key] = values
+	}
+	if q.Get("countryCode") == "" {
+		q.Set("countryCode", s.CountryCode)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       respBody,
+	}, nil
+}
+
+func (s *Service) refreshIfExpiring() error {
+	expiry := s.Config.Get().Tidal.Expiry
+	// unknown expiry, rely on the 401 retry
+	if expiry.IsZero() || time.Until(expiry) > tokenRefreshWindow {
+		return nil
+	}
+
+	log.Info().Msg("Tidal access token expiring, refreshing")
+	return s.refreshAccessToken()
+}
+
+// refreshAccessToken refreshes the user's access token and saves it with its expiry
+func (s *Service) refreshAccessToken() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.Config.Get()
+
+	refresh, err := s.refreshSession(c.Tidal.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	c.Tidal.AccessToken = refresh.AccessToken
+	if refresh.RefreshToken != "" {
+		c.Tidal.RefreshToken = refresh.RefreshToken
+	}
+	c.Tidal.Expiry = time.Now().Add(time.Duration(refresh.ExpiresIn) * time.Second)
+	if refresh.User.CountryCode != "" {
+		c.Tidal.CountryCode = refresh.User.CountryCode
+	}
+
+	if err := s.Config.Update(c); err != nil {
+		return fmt.Errorf("error updating tidal config: %w", err)
+	}
+
+	s.AccessToken = refresh.AccessToken
+
+	return nil
+}
+
+func (s *Service) refreshClientToken() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clientAccessToken, err := s.clientAuth(s.ClientId, s.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	s.ClientAccessToken = clientAccessToken
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package tidal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const openApiURL = "https://openapi.tidal.com/v2"
+
+type SearchType string
+
+const (
+	SearchTypeTracks    SearchType = "TRACKS"
+	SearchTypeAlbums    SearchType = "ALBUMS"
+	SearchTypeArtists   SearchType = "ARTISTS"
+	SearchTypePlaylists SearchType = "PLAYLISTS"
+)
+
+// openApiTracksResponse is the JSON:API document returned by the openapi tracks endpoint.
+// Only the resource IDs are used, full tracks are fetched from the v1 API.
+type openApiTracksResponse struct {
+	Data []struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	} `json:"data"`
+}
+
+func searchParams(query string, limit, offset int) map[string]string {
+	return map[string]string{
+		"query":  query,
+		"limit":  strconv.Itoa(limit),
+		"offset": strconv.Itoa(offset),
+	}
+}
+
+// Search searches the Tidal catalog for all of the provided types in a single request
+func (s *Service) Search(query string, types []SearchType, limit, offset int) (*TrackSearch, error) {
+	params := searchParams(query, limit, offset)
+	if len(types) > 0 {
+		typeStrings := make([]string, 0, len(types))
+		for _, t := range types {
+			typeStrings = append(typeStrings, string(t))
+		}
+		params["types"] = strings.Join(typeStrings, ",")
+	}
+
+	body, err := s.standardHttpGetRequest(fmt.Sprintf("%s/search", apiURL), params)
+	if err != nil {
+		return nil, err
+	}
+
+	var search TrackSearch
+	err = json.Unmarshal(body, &search)
+	if err != nil {
+		return nil, err
+	}
+
+	return &search, nil
+}
+
+func (s *Service) SearchTracks(query string, limit, offset int) (*SearchTracksPagination, error) {
+	body, err := s.standardHttpGetRequest(fmt.Sprintf("%s/search/tracks", apiURL), searchParams(query, limit, offset))
+	if err != nil {
+		return nil, err
+	}
+
+	var searchTracks SearchTracksPagination
+	err = json.Unmarshal(body, &searchTracks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &searchTracks, nil
+}
+
+func (s *Service) SearchAlbums(query string, limit, offset int) (*SearchAlbumsPagination, error) {
+	body, err := s.standardHttpGetRequest(fmt.Sprintf("%s/search/albums", apiURL), searchParams(query, limit, offset))
+	if err != nil {
+		return nil, err
+	}
+
+	var searchAlbums SearchAlbumsPagination
+	err = json.Unmarshal(body, &searchAlbums)
+	if err != nil {
+		return nil, err
+	}
+
+	return &searchAlbums, nil
+}
+
+func (s *Service) SearchArtists(query string, limit, offset int) (*SearchArtistsPagination, error) {
+	body, err := s.standardHttpGetRequest(fmt.Sprintf("%s/search/artists", apiURL), searchParams(query, limit, offset))
+	if err != nil {
+		return nil, err
+	}
+
+	var searchArtists SearchArtistsPagination
+	err = json.Unmarshal(body, &searchArtists)
+	if err != nil {
+		return nil, err
+	}
+
+	return &searchArtists, nil
+}
+
+func (s *Service) SearchPlaylists(query string, limit, offset int) (*SearchPlaylistsPagination, error) {
+	body, err := s.standardHttpGetRequest(fmt.Sprintf("%s/search/playlists", apiURL), searchParams(query, limit, offset))
+	if err != nil {
+		return nil, err
+	}
+
+	var searchPlaylists SearchPlaylistsPagination
+	err = json.Unmarshal(body, &searchPlaylists)
+	if err != nil {
+		return nil, err
+	}
+
+	return &searchPlaylists, nil
+}
+
+func (s *Service) GetTrack(trackID string) (*Track, error) {
+	body, err := s.standardHttpGetRequest(fmt.Sprintf("%s/tracks/%s", apiURL, trackID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var track Track
+	err = json.Unmarshal(body, &track)
+	if err != nil {
+		return nil, err
+	}
+
+	return &track, nil
+}
+
+// GetTracksByISRC looks up all tracks with the ISRC.
+// The v1 API has no ISRC filter so the track IDs are resolved using the openapi with the client credentials token.
+func (s *Service) GetTracksByISRC(isrc string) ([]Track, error) {
+	q := url.Values{}
+	q.Add("filter[isrc]", isrc)
+
+	resp, err := s.do(apiRequest{
+		method:     "GET",
+		url:        fmt.Sprintf("%s/tracks", openApiURL),
+		query:      q,
+		headers:    map[string]string{"Accept": "application/vnd.api+json"},
+		clientAuth: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to lookup isrc: %s", string(resp.Body))
+	}
+
+	var openApiTracks openApiTracksResponse
+	err = json.Unmarshal(resp.Body, &openApiTracks)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := make([]Track, 0, len(openApiTracks.Data))
+	for _, data := range openApiTracks.Data {
+		track, err := s.GetTrack(data.ID)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, *track)
+	}
+
+	return tracks, nil
+}
@@ -1,12 +1,17 @@
 package tidal
 
 import (
+	"fmt"
+	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/zibbp/music-utils/config"
+	"github.com/zibbp/music-utils/matcher"
 	"github.com/zibbp/music-utils/navidrome"
+	"github.com/zibbp/music-utils/utils"
 )
 
 var (
@@ -14,16 +19,24 @@ var (
 	apiURL2 = "https://listen.tidal.com/v2"
 )
 
+// Used when the session does not report a country
+const defaultCountryCode = "US"
+
 type Service struct {
+	mu                sync.Mutex
 	ClientId          string
 	ClientSecret      string
 	AccessToken       string // device-flow user resources access token
 	ClientAccessToken string // application client for accessing Tidal API non-user resources
 	UserID            string
+	CountryCode       string // country used for availability and search results
 	Config            *config.JsonConfigService
+	httpClient        *http.Client
 }
 
-func Initialize(clientId, clientSecret string, config *config.JsonConfigService) (*Service, error) {
+// Initialize creates the Tidal service. If countryCode is empty the country of the user's session is used.
+// All requests are sent with httpClient.
+func Initialize(clientId, clientSecret, countryCode string, httpClient *http.Client, config *config.JsonConfigService) (*Service, error) {
 	if clientId == "" || clientSecret == "" {
 		log.Fatal().Msg("Tidal client ID and secret not provided, check env vars")
 	}
@@ -31,7 +44,9 @@ func Initialize(clientId, clientSecret string, config *config.JsonConfigService)
 	var s Service
 	s.ClientId = clientId
 	s.ClientSecret = clientSecret
+	s.CountryCode = countryCode
 	s.Config = config
+	s.httpClient = httpClient
 
 	if s.Config.Get().Tidal.AccessToken != "" {
 		s.AccessToken = s.Config.Get().Tidal.AccessToken
@@ -82,6 +97,8 @@ func (s *Service) DeviceAuthenticate() error {
 				s.Config.JsonConfig.Tidal.UserID = strconv.Itoa(int(loginResponse.AuthLogin.User.UserID))
 				s.Config.JsonConfig.Tidal.AccessToken = loginResponse.AuthLogin.AccessToken
 				s.Config.JsonConfig.Tidal.RefreshToken = loginResponse.AuthLogin.RefreshToken
+				s.Config.JsonConfig.Tidal.Expiry = time.Now().Add(time.Duration(loginResponse.AuthLogin.ExpiresIn) * time.Second)
+				s.Config.JsonConfig.Tidal.CountryCode = loginResponse.AuthLogin.User.CountryCode
 				s.Config.Save()
 				break
 			}
@@ -93,18 +110,16 @@ func (s *Service) DeviceAuthenticate() error {
 		}
 	} else {
 		log.Info().Msg("Tidal access token found")
-		_, err := s.checkSession(s.Config.Get().Tidal.AccessToken)
+		session, err := s.checkSession(s.Config.Get().Tidal.AccessToken)
 		if err != nil {
 			// failed probably need to refresh
 			log.Info().Msg("Tidal access token expired")
-			refresh, err := s.refreshSession(s.Config.Get().Tidal.RefreshToken)
-			if err != nil {
+			if err := s.refreshAccessToken(); err != nil {
 				return err
 			}
-
-			s.Config.JsonConfig.Tidal.AccessToken = refresh.AccessToken
+		} else if session.CountryCode != "" && session.CountryCode != s.Config.Get().Tidal.CountryCode {
+			s.Config.JsonConfig.Tidal.CountryCode = session.CountryCode
 			s.Config.Save()
-
 		}
 
 		log.Info().Msg("Tidal access token valid")
@@ -113,27 +128,76 @@ func (s *Service) DeviceAuthenticate() error {
 	s.AccessToken = s.Config.Get().Tidal.AccessToken
 	s.UserID = s.Config.Get().Tidal.UserID
 
+	// an explicitly configured country takes precedence over the session's
+	if s.CountryCode == "" {
+		s.CountryCode = s.Config.Get().Tidal.CountryCode
+	}
+	if s.CountryCode == "" {
+		s.CountryCode = defaultCountryCode
+	}
+	log.Debug().Msgf("Tidal country code: %s", s.CountryCode)
+
 	return nil
 }
 
+// ToNavidromePlaylist converts the playlist to the Navidrome format. Tracks without artists are
+// reported and kept with an empty artist.
 func (s *Service) ToNavidromePlaylist(playlist *Playlist) (navidrome.Playlist, error) {
 	var np navidrome.Playlist
+	if playlist == nil {
+		return np, fmt.Errorf("playlist is nil")
+	}
 
 	np.SourceId = playlist.UUID
 	np.Name = playlist.Title
 	np.Description = playlist.Description
-	np.Tracks = make([]navidrome.Track, 0)
+	np.Tracks = make([]navidrome.Track, 0, len(playlist.Tracks))
 
 	for _, track := range playlist.Tracks {
-		np.Tracks = append(np.Tracks, navidrome.Track{
-			ID:       strconv.FormatInt(track.ID, 10),
-			Title:    track.Title,
-			Artist:   track.Artists[0].Name,
-			Album:    track.Album.Title,
-			Duration: track.Duration,
-			ISRC:     track.Isrc,
-		})
+		navidromeTrack := navidrome.FromMatcherTrack(track.ToMatcherTrack())
+		if navidromeTrack.Artist == "" {
+			log.Warn().Str("playlist", playlist.Title).Int64("id", track.ID).Str("track", track.Title).Msg("tidal track has no artists")
+		}
+		np.Tracks = append(np.Tracks, navidromeTrack)
 	}
 
 	return np, nil
 }
+
+// ToMatcherTrack converts the track for use with the matcher
+func (t Track) ToMatcherTrack() matcher.Track {
+	artists := make([]string, 0, len(t.Artists))
+	for _, artist := range t.Artists {
+		artists = append(artists, artist.Name)
+	}
+	if len(artists) == 0 && t.Artist.Name != "" {
+		artists = append(artists, t.Artist.Name)
+	}
+
+	version := ""
+	if t.Version != nil {
+		version = *t.Version
+	}
+
+	// album artists are only known for tracks from album endpoints and search results. The track
+	// artist is not used instead as it is wrong for compilations.
+	albumArtist := ""
+	if len(t.Album.Artists) > 0 {
+		albumArtist = t.Album.Artists[0].Name
+	}
+
+	return matcher.Track{
+		ID:          strconv.FormatInt(t.ID, 10),
+		ISRC:        t.Isrc,
+		Title:       t.Title,
+		Version:     version,
+		Artists:     artists,
+		Album:       t.Album.Title,
+		Duration:    time.Duration(t.Duration) * time.Second,
+		Explicit:    t.Explicit,
+		AlbumArtist: albumArtist,
+		TrackNumber: int(t.TrackNumber),
+		DiscNumber:  int(t.VolumeNumber),
+		Year:        utils.ReleaseYear(t.Album.ReleaseDate),
+	}
+}
@@ -2,16 +2,17 @@ package tidal
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
-	"strings"
+	"strconv"
 
 	"github.com/rs/zerolog/log"
 )
 
-const countryCode = "US"
+// ErrNotFound is returned when the requested item does not exist on Tidal
+var ErrNotFound = errors.New("not found on tidal")
 
 type CreatedPlaylist struct {
 	Trn            string      `json:"trn"`
@@ -101,6 +102,9 @@ type Album struct {
 	VibrantColor string  `json:"vibrantColor"`
 	VideoCover   *string `json:"videoCover"`
 	ReleaseDate  string  `json:"releaseDate"`
+	// Only populated by album endpoints and search results
+	Artists        []Artist `json:"artists,omitempty"`
+	NumberOfTracks int64    `json:"numberOfTracks,omitempty"`
 }
 
 type Artist struct {
@@ -116,12 +120,12 @@ type Mixes struct {
 }
 
 type TrackSearch struct {
-	Artists   SearchTracksPagination `json:"artists"`
-	Albums    SearchTracksPagination `json:"albums"`
-	Playlists SearchTracksPagination `json:"playlists"`
-	Tracks    SearchTracksPagination `json:"tracks"`
-	Videos    SearchTracksPagination `json:"videos"`
-	TopHit    TopHit                 `json:"topHit"`
+	Artists   SearchArtistsPagination   `json:"artists"`
+	Albums    SearchAlbumsPagination    `json:"albums"`
+	Playlists SearchPlaylistsPagination `json:"playlists"`
+	Tracks    SearchTracksPagination    `json:"tracks"`
+	Videos    SearchTracksPagination    `json:"videos"`
+	TopHit    TopHit                    `json:"topHit"`
 }
 
 type SearchTracksPagination struct {
@@ -131,6 +135,27 @@ type SearchTracksPagination struct {
 	Items              []Track `json:"items"`
 }
 
+type SearchAlbumsPagination struct {
+	Limit              int64   `json:"limit"`
+	Offset             int64   `json:"offset"`
+	TotalNumberOfItems int64   `json:"totalNumberOfItems"`
+	Items              []Album `json:"items"`
+}
+
+type SearchArtistsPagination struct {
+	Limit              int64    `json:"limit"`
+	Offset             int64    `json:"offset"`
+	TotalNumberOfItems int64    `json:"totalNumberOfItems"`
+	Items              []Artist `json:"items"`
+}
+
+type SearchPlaylistsPagination struct {
+	Limit              int64      `json:"limit"`
+	Offset             int64      `json:"offset"`
+	TotalNumberOfItems int64      `json:"totalNumberOfItems"`
+	Items              []Playlist `json:"items"`
+}
+
 type UserPlaylists struct {
 	Limit              int64      `json:"limit"`
 	Offset             int64      `json:"offset"`
@@ -191,42 +216,25 @@ type TopHit struct {
 }
 
 func (s *Service) standardHttpGetRequest(reqUrl string, params map[string]string) ([]byte, error) {
-	client := &http.Client{}
-
-	req, err := http.NewRequest("GET", reqUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	// Set Headers
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.AccessToken))
-
 	// Set Query Params
-	q := req.URL.Query()
-	q.Add("countryCode", countryCode)
-	// q.Add("limit", "10000")
+	q := url.Values{}
 	for key, value := range params {
 		q.Add(key, value)
 	}
-	req.URL.RawQuery = q.Encode()
 
-	resp, err := client.Do(req)
+	resp, err := s.do(apiRequest{method: "GET", url: reqUrl, query: q})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, string(resp.Body))
 	}
-
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s", string(body))
+		return nil, fmt.Errorf("%s", string(resp.Body))
 	}
 
-	return body, nil
+	return resp.Body, nil
 }
 
 func (s *Service) GetUserPlaylists() ([]PlaylistItemV2, error) {
@@ -287,7 +295,7 @@ loop:
 }
 
 func (s *Service) GetPlaylist(playlistID string) (*Playlist, error) {
-	playlist, err := s.standardHttpGetRequest(fmt.Sprintf("%s/playlists/%s", apiURL, playlistID), map[string]string{"limit": "10000"})
+	playlist, err := s.standardHttpGetRequest(fmt.Sprintf("%s/playlists/%s", apiURL, playlistID), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -302,41 +310,22 @@ func (s *Service) GetPlaylist(playlistID string) (*Playlist, error) {
 }
 
 func (s *Service) CreatePlaylist(name, description string) (*Playlist, error) {
-	client := &http.Client{}
-
-	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/my-collection/playlists/folders/create-playlist", apiURL2), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.AccessToken)
-
 	params := url.Values{}
 	params.Set("folderId", "root")
 	params.Set("name", name)
 	params.Set("description", description)
 
-	req.URL.RawQuery = params.Encode()
-
-	resp, err := client.Do(req)
+	resp, err := s.do(apiRequest{method: "PUT", url: fmt.Sprintf("%s/my-collection/playlists/folders/create-playlist", apiURL2), query: params, notIdempotent: true})
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
-
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to create playlist: %s", resp.Status)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create playlist: %d %s", resp.StatusCode, string(resp.Body))
 	}
 
 	var createdPlaylist CreatedPlaylist
-	err = json.Unmarshal(body, &createdPlaylist)
+	err = json.Unmarshal(resp.Body, &createdPlaylist)
 	if err != nil {
 		return nil, err
 	}
@@ -346,69 +335,30 @@ func (s *Service) CreatePlaylist(name, description string) (*Playlist, error) {
 
 func (s *Service) UpdatePlaylist(playlistID, name, description string) error {
 	// updated name and description sent in body no params
-	client := &http.Client{}
-
 	data := url.Values{}
 	data.Set("title", name)
 	data.Set("description", description)
 
-	encodedData := data.Encode()
-
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/playlists/%s", apiURL, playlistID), strings.NewReader(encodedData))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.AccessToken)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	resp, err := client.Do(req)
+	resp, err := s.do(apiRequest{method: "POST", url: fmt.Sprintf("%s/playlists/%s", apiURL, playlistID), form: data})
 	if err != nil {
 		return err
 	}
 
-	defer resp.Body.Close()
-
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("failed to update playlist: %s", resp.Status)
+		return fmt.Errorf("failed to update playlist: %d %s", resp.StatusCode, string(resp.Body))
 	}
 
 	return nil
 }
 
 func (s *Service) getPlaylistEtag(id string) (string, error) {
-	client := &http.Client{}
-
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/playlists/%s", apiURL, id), nil)
-	if err != nil {
-		return "", err
-	}
-
-	// Set Headers
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.AccessToken))
-
-	// Set Query Params
-	q := url.Values{}
-	q.Add("countryCode", countryCode)
-
-	req.URL.RawQuery = q.Encode()
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	resp, err := s.do(apiRequest{method: "GET", url: fmt.Sprintf("%s/playlists/%s", apiURL, id)})
 	if err != nil {
 		return "", err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("%s", string(body))
+		return "", fmt.Errorf("%s", string(resp.Body))
 	}
 
 	playlistEtag := resp.Header.Get("ETag")
@@ -422,39 +372,17 @@ func (s *Service) AddTrackToPlaylist(playlistId string, trackId string) error {
 		return err
 	}
 
-	client := &http.Client{}
-
 	data := url.Values{}
 	data.Set("trackIds", fmt.Sprintf("%v", trackId))
 	data.Set("onArtifactNotFound", "FAIL")
 	data.Set("onDupes", "FAIL")
 
-	encodedData := data.Encode()
-
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/playlists/%s/items", apiURL, playlistId), strings.NewReader(encodedData))
-	if err != nil {
-		return err
-	}
-
-	// Set Headers
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.AccessToken))
-	req.Header.Set("If-None-Match", playlistEtag)
-
-	// Set Query Params
-	q := url.Values{}
-	q.Add("countryCode", countryCode)
-
-	req.URL.RawQuery = q.Encode()
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	resp, err := s.do(apiRequest{
+		method:  "POST",
+		url:     fmt.Sprintf("%s/playlists/%s/items", apiURL, playlistId),
+		form:    data,
+		headers: map[string]string{"If-None-Match": playlistEtag},
+	})
 	if err != nil {
 		return err
 	}
@@ -463,25 +391,49 @@ func (s *Service) AddTrackToPlaylist(playlistId string, trackId string) error {
 		if resp.StatusCode == http.StatusConflict {
 			log.Debug().Msgf("Track %v already exists in playlist %s", trackId, playlistId)
 		} else {
-			return fmt.Errorf("failed to add track to playlist: %s", string(body))
+			return fmt.Errorf("failed to add track to playlist: %s", string(resp.Body))
 		}
-
-		return err
 	}
 
 	return nil
 }
 
+// Number of playlist tracks requested per page
+const playlistTracksPageSize = 100
+
+// GetPlaylistTracks fetches all tracks of the playlist, paging until the reported total is reached
 func (s *Service) GetPlaylistTracks(id string) (*TidalPlaylistTracks, error) {
-	body, err := s.standardHttpGetRequest(fmt.Sprintf("%s/playlists/%s/tracks", apiURL, id), map[string]string{"limit": "10000"})
-	if err != nil {
-		return nil, err
+	var tidalPlaylistTracks TidalPlaylistTracks
+
+	for offset := 0; ; {
+		body, err := s.standardHttpGetRequest(fmt.Sprintf("%s/playlists/%s/tracks", apiURL, id), map[string]string{
+			"limit":  strconv.Itoa(playlistTracksPageSize),
+			"offset": strconv.Itoa(offset),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var page TidalPlaylistTracks
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		tidalPlaylistTracks.TotalNumberOfItems = page.TotalNumberOfItems
+		tidalPlaylistTracks.Items = append(tidalPlaylistTracks.Items, page.Items...)
+
+		// pages can be shorter than the limit
+		offset += len(page.Items)
+		if len(page.Items) == 0 || int64(offset) >= page.TotalNumberOfItems {
+			break
+		}
 	}
 
-	var tidalPlaylistTracks TidalPlaylistTracks
-	err = json.Unmarshal(body, &tidalPlaylistTracks)
-	if err != nil {
-		return nil, err
+	tidalPlaylistTracks.Limit = int64(len(tidalPlaylistTracks.Items))
+
+	if int64(len(tidalPlaylistTracks.Items)) != tidalPlaylistTracks.TotalNumberOfItems {
+		log.Warn().Str("playlist", id).Int("fetched", len(tidalPlaylistTracks.Items)).Int64("total", tidalPlaylistTracks.TotalNumberOfItems).Msg("fetched tidal playlist tracks do not match reported total")
 	}
 
 	return &tidalPlaylistTracks, nil
@@ -0,0 +1,198 @@
+// Package trackcache stores which tracks on different providers are the same song so
+// repeat transfers can skip searching.
+package trackcache
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS track_mappings (
+	source_provider TEXT NOT NULL,
+	source_id TEXT NOT NULL,
+	destination_provider TEXT NOT NULL,
+	destination_id TEXT NOT NULL,
+	isrc TEXT NOT NULL DEFAULT '',
+	confidence REAL NOT NULL,
+	method TEXT NOT NULL,
+	verified_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (source_provider, source_id, destination_provider)
+);
+CREATE TABLE IF NOT EXISTS isrc_tracks (
+	isrc TEXT NOT NULL,
+	provider TEXT NOT NULL,
+	track_id TEXT NOT NULL,
+	verified_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (isrc, provider)
+);
+`
+
+// Cache is safe to use when nil, lookups then find nothing and saves are ignored
+type Cache struct {
+	db *sql.DB
+}
+
+// Mapping links a track on one provider to the same track on another
+type Mapping struct {
+	SourceProvider      string
+	SourceID            string
+	DestinationProvider string
+	DestinationID       string
+	ISRC                string
+	// Confidence of the match between 0 and 1
+	Confidence float64
+	// Method used to match the tracks, e.g. isrc or metadata
+	Method     string
+	VerifiedAt time.Time
+}
+
+// Open opens the cache database at path, creating it if needed
+func Open(path string) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL", path))
+	if err != nil {
+		return nil, fmt.Errorf("error opening track cache: %v", err)
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating track cache tables: %v", err)
+	}
+
+	return &Cache{db: db}, nil
+}
+
+func (c *Cache) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.db.Close()
+}
+
+// Lookup finds the destination track for a source track. Mappings of the source ID are checked
+// first, in either direction, then any track on the destination with the same ISRC.
+func (c *Cache) Lookup(sourceProvider, sourceID, isrc, destinationProvider string) (*Mapping, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	m := Mapping{
+		SourceProvider:      sourceProvider,
+		SourceID:            sourceID,
+		DestinationProvider: destinationProvider,
+		ISRC:                isrc,
+	}
+
+	err := c.db.QueryRow(`SELECT destination_id, isrc, confidence, method, verified_at FROM track_mappings
+		WHERE source_provider = ? AND source_id = ? AND destination_provider = ?`,
+		sourceProvider, sourceID, destinationProvider).Scan(&m.DestinationID, &m.ISRC, &m.Confidence, &m.Method, &m.VerifiedAt)
+	if err == nil {
+		return &m, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	err = c.db.QueryRow(`SELECT source_id, isrc, confidence, method, verified_at FROM track_mappings
+		WHERE destination_provider = ? AND destination_id = ? AND source_provider = ?`,
+		sourceProvider, sourceID, destinationProvider).Scan(&m.DestinationID, &m.ISRC, &m.Confidence, &m.Method, &m.VerifiedAt)
+	if err == nil {
+		return &m, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if isrc == "" {
+		return nil, nil
+	}
+
+	err = c.db.QueryRow(`SELECT track_id, verified_at FROM isrc_tracks WHERE isrc = ? AND provider = ?`,
+		isrc, destinationProvider).Scan(&m.DestinationID, &m.VerifiedAt)
+	if err == nil {
+		m.Confidence = 1
+		m.Method = "isrc"
+		return &m, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// Save stores the mapping and the ISRC of the source track, and of the destination track when
+// matched by ISRC. VerifiedAt defaults to now.
+func (c *Cache) Save(m Mapping) error {
+	if c == nil {
+		return nil
+	}
+	if m.VerifiedAt.IsZero() {
+		m.VerifiedAt = time.Now().UTC()
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO track_mappings (source_provider, source_id, destination_provider, destination_id, isrc, confidence, method, verified_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (source_provider, source_id, destination_provider) DO UPDATE SET
+			destination_id = excluded.destination_id, isrc = excluded.isrc, confidence = excluded.confidence,
+			method = excluded.method, verified_at = excluded.verified_at`,
+		m.SourceProvider, m.SourceID, m.DestinationProvider, m.DestinationID, m.ISRC, m.Confidence, m.Method, m.VerifiedAt); err != nil {
+		return fmt.Errorf("error saving track mapping: %v", err)
+	}
+
+	if m.ISRC != "" {
+		tracks := [][2]string{{m.SourceProvider, m.SourceID}}
+		// a metadata match may be a different recording with its own ISRC
+		if m.Method == "isrc" {
+			tracks = append(tracks, [2]string{m.DestinationProvider, m.DestinationID})
+		}
+		for _, track := range tracks {
+			if _, err := tx.Exec(`INSERT INTO isrc_tracks (isrc, provider, track_id, verified_at) VALUES (?, ?, ?, ?)
+				ON CONFLICT (isrc, provider) DO UPDATE SET track_id = excluded.track_id, verified_at = excluded.verified_at`,
+				m.ISRC, track[0], track[1], m.VerifiedAt); err != nil {
+				return fmt.Errorf("error saving track isrc: %v", err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the mappings to and from the track and its ISRC, e.g. when the track is no longer available
+func (c *Cache) Delete(provider, trackID string) error {
+	if c == nil {
+		return nil
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM track_mappings WHERE (source_provider = ? AND source_id = ?) OR (destination_provider = ? AND destination_id = ?)`,
+		provider, trackID, provider, trackID); err != nil {
+		return fmt.Errorf("error deleting track mappings: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM isrc_tracks WHERE provider = ? AND track_id = ?`, provider, trackID); err != nil {
+		return fmt.Errorf("error deleting track isrc: %v", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,88 @@
+package trackcache
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	mappings := []Mapping{
+		{SourceProvider: "spotify", SourceID: "spotify:track:1", DestinationProvider: "tidal", DestinationID: "101", ISRC: "USAAA0000001", Confidence: 1, Method: "isrc"},
+		// a metadata match may be another recording, only the source ISRC is saved
+		{SourceProvider: "spotify", SourceID: "spotify:track:2", DestinationProvider: "tidal", DestinationID: "102", ISRC: "USAAA0000002", Confidence: 0.9, Method: "metadata"},
+	}
+
+	tests := []struct {
+		name                string
+		deleteProvider      string
+		deleteID            string
+		sourceProvider      string
+		sourceID            string
+		isrc                string
+		destinationProvider string
+		// empty when nothing should be found
+		want       string
+		wantMethod string
+	}{
+		{name: "forward", sourceProvider: "spotify", sourceID: "spotify:track:1", destinationProvider: "tidal", want: "101", wantMethod: "isrc"},
+		{name: "reverse", sourceProvider: "tidal", sourceID: "102", destinationProvider: "spotify", want: "spotify:track:2", wantMethod: "metadata"},
+		{name: "other provider", sourceProvider: "spotify", sourceID: "spotify:track:1", destinationProvider: "navidrome"},
+		{name: "isrc of an isrc match", sourceProvider: "navidrome", sourceID: "n1", isrc: "USAAA0000001", destinationProvider: "tidal", want: "101", wantMethod: "isrc"},
+		{name: "isrc of the source", sourceProvider: "navidrome", sourceID: "n2", isrc: "USAAA0000002", destinationProvider: "spotify", want: "spotify:track:2", wantMethod: "isrc"},
+		{name: "isrc of a metadata match", sourceProvider: "navidrome", sourceID: "n2", isrc: "USAAA0000002", destinationProvider: "tidal"},
+		{name: "unknown isrc", sourceProvider: "navidrome", sourceID: "n3", isrc: "USAAA0000003", destinationProvider: "tidal"},
+		{name: "deleted destination", deleteProvider: "tidal", deleteID: "101", sourceProvider: "spotify", sourceID: "spotify:track:1", destinationProvider: "tidal"},
+		{name: "deleted destination isrc", deleteProvider: "tidal", deleteID: "101", sourceProvider: "navidrome", sourceID: "n1", isrc: "USAAA0000001", destinationProvider: "tidal"},
+		{name: "deleted source", deleteProvider: "spotify", deleteID: "spotify:track:2", sourceProvider: "tidal", sourceID: "102", destinationProvider: "spotify"},
+		{name: "other track kept", deleteProvider: "tidal", deleteID: "101", sourceProvider: "spotify", sourceID: "spotify:track:2", destinationProvider: "tidal", want: "102", wantMethod: "metadata"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := Open(filepath.Join(t.TempDir(), "tracks.db"))
+			if err != nil {
+				t.Fatalf("error opening cache: %v", err)
+			}
+			defer c.Close()
+
+			for _, m := range mappings {
+				if err := c.Save(m); err != nil {
+					t.Fatalf("error saving mapping: %v", err)
+				}
+			}
+			if test.deleteID != "" {
+				if err := c.Delete(test.deleteProvider, test.deleteID); err != nil {
+					t.Fatalf("error deleting track: %v", err)
+				}
+			}
+
+			m, err := c.Lookup(test.sourceProvider, test.sourceID, test.isrc, test.destinationProvider)
+			if err != nil {
+				t.Fatalf("error looking up mapping: %v", err)
+			}
+			switch {
+			case test.want == "" && m != nil:
+				t.Errorf("found %s, want nothing", m.DestinationID)
+			case test.want != "" && m == nil:
+				t.Errorf("found nothing, want %s", test.want)
+			case m != nil && (m.DestinationID != test.want || m.Method != test.wantMethod):
+				t.Errorf("found %s by %s, want %s by %s", m.DestinationID, m.Method, test.want, test.wantMethod)
+			case m != nil && m.VerifiedAt.IsZero():
+				t.Errorf("verified_at is not set")
+			}
+		})
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	if err := c.Save(Mapping{SourceProvider: "spotify", SourceID: "1", DestinationProvider: "tidal", DestinationID: "2"}); err != nil {
+		t.Errorf("error saving to nil cache: %v", err)
+	}
+	if m, err := c.Lookup("spotify", "1", "", "tidal"); m != nil || err != nil {
+		t.Errorf("lookup in nil cache = %v, %v", m, err)
+	}
+	if err := c.Delete("tidal", "2"); err != nil {
+		t.Errorf("error deleting from nil cache: %v", err)
+	}
+}
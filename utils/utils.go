@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 )
 
 // WriteJsonToFile writes data to path+filename.json
@@ -19,3 +20,31 @@ func WriteJsonToFile(path string, filename string, data interface{}) error {
 
 	return os.WriteFile(fmt.Sprintf("%s/%s.json", path, filename), json, 0644)
 }
+
+// ReadJsonFromFile reads path+filename.json into data
+func ReadJsonFromFile(path string, filename string, data interface{}) error {
+	b, err := os.ReadFile(fmt.Sprintf("%s/%s.json", path, filename))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, data)
+}
+
+// JsonFileExists checks if path+filename.json exists
+func JsonFileExists(path string, filename string) bool {
+	_, err := os.Stat(fmt.Sprintf("%s/%s.json", path, filename))
+	return err == nil
+}
+
+// ReleaseYear returns the year of a release date such as 2006-01-02 or 0 if it has none
+func ReleaseYear(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}